@@ -0,0 +1,227 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+)
+
+// algorithms holds the functions in the algorithm table of a type. The hash
+// and equal functions are nil for types which are not comparable, and the
+// print function is nil for types the runtime has no print function for.
+type algorithms struct {
+	hash, equal, print, copy llvm.Value
+}
+
+// algorithms returns the algorithm functions for the specified type. Most
+// types use functions defined in the runtime; the functions for structs and
+// arrays are generated, and apply the algorithms of the fields or elements
+// in turn.
+func (tm *TypeMap) algorithms(t types.Type) *algorithms {
+	t = types.Underlying(t)
+	if algs, ok := tm.algs[t]; ok {
+		return algs
+	}
+
+	var algs *algorithms
+	switch t := t.(type) {
+	case *types.Basic:
+		switch t.Kind {
+		case types.Float32Kind:
+			algs = tm.runtimeAlgorithms("f32")
+		case types.Float64Kind:
+			algs = tm.runtimeAlgorithms("f64")
+		case types.Complex64Kind:
+			algs = tm.runtimeAlgorithms("c64")
+		case types.Complex128Kind:
+			algs = tm.runtimeAlgorithms("c128")
+		case types.StringKind:
+			algs = tm.runtimeAlgorithms("str")
+		default:
+			algs = tm.runtimeAlgorithms("mem")
+		}
+	case *types.Interface:
+		if len(t.Methods) == 0 {
+			algs = tm.runtimeAlgorithms("nilinter")
+		} else {
+			algs = tm.runtimeAlgorithms("inter")
+		}
+	case *types.Struct:
+		elems := make([]types.Type, len(t.Fields))
+		for i, f := range t.Fields {
+			elems[i] = f.Type.(types.Type)
+		}
+		algs = tm.compositeAlgorithms(t, elems, -1)
+	case *types.Array:
+		algs = tm.compositeAlgorithms(t, []types.Type{t.Elt}, int(t.Len))
+	case *types.Slice, *types.Map, *types.Func:
+		algs = tm.runtimeAlgorithms("mem")
+		algs.hash = llvm.ConstNull(llvm.PointerType(tm.hashAlgFunctionType, 0))
+		algs.equal = llvm.ConstNull(llvm.PointerType(tm.equalAlgFunctionType, 0))
+	default: // pointers and channels
+		algs = tm.runtimeAlgorithms("mem")
+	}
+	tm.algs[t] = algs
+	return algs
+}
+
+// runtimeAlgorithm returns the named runtime algorithm function, declaring
+// it if necessary.
+func (tm *TypeMap) runtimeAlgorithm(name string, fntype llvm.Type) llvm.Value {
+	name = "runtime." + name
+	fn := tm.module.NamedFunction(name)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(tm.module, name, fntype)
+	}
+	return fn
+}
+
+// runtimeAlgorithms returns the runtime algorithm functions with the given
+// prefix. Values of all types are copied with memcopy; only floats,
+// complex numbers and strings have print functions.
+func (tm *TypeMap) runtimeAlgorithms(prefix string) *algorithms {
+	algs := &algorithms{
+		hash:  tm.runtimeAlgorithm(prefix+"hash", tm.hashAlgFunctionType),
+		equal: tm.runtimeAlgorithm(prefix+"equal", tm.equalAlgFunctionType),
+		print: llvm.ConstNull(llvm.PointerType(tm.printAlgFunctionType, 0)),
+		copy:  tm.runtimeAlgorithm("memcopy", tm.copyAlgFunctionType),
+	}
+	switch prefix {
+	case "f32", "f64", "c64", "c128", "str":
+		algs.print = tm.runtimeAlgorithm(prefix+"print", tm.printAlgFunctionType)
+	}
+	return algs
+}
+
+// compositeAlgorithms generates the hash and equal functions for a struct
+// with the specified field types (in which case n is negative), or for an
+// array of n elements of the (single) specified element type. Structs and
+// arrays are comparable only if their fields or elements are; blank fields
+// of structs are ignored when hashing and comparing.
+func (tm *TypeMap) compositeAlgorithms(t types.Type, elems []types.Type, n int) *algorithms {
+	algs := tm.runtimeAlgorithms("mem")
+	elemalgs := make([]*algorithms, len(elems))
+	for i, elem := range elems {
+		elemalgs[i] = tm.algorithms(elem)
+		if elemalgs[i].equal.IsNull() {
+			algs.hash = llvm.ConstNull(llvm.PointerType(tm.hashAlgFunctionType, 0))
+			algs.equal = llvm.ConstNull(llvm.PointerType(tm.equalAlgFunctionType, 0))
+			return algs
+		}
+	}
+
+	// blank reports whether the j'th element type is that of a blank
+	// struct field.
+	blank := func(j int) bool {
+		s, ok := t.(*types.Struct)
+		return ok && s.Fields[j].Name == "_"
+	}
+
+	builder := llvm.NewBuilder()
+	defer builder.Dispose()
+	ptrType := llvm.PointerType(tm.ToLLVM(t), 0)
+	uintptrType := tm.target.IntPtrType()
+	zero := llvm.ConstNull(llvm.Int32Type())
+
+	// elemptr returns the address of the i'th field or element of the
+	// value at p, and the size of the field or element.
+	elemptr := func(p llvm.Value, i llvm.Value, elem types.Type) (llvm.Value, llvm.Value) {
+		p = builder.CreateIntToPtr(p, ptrType, "")
+		p = builder.CreateGEP(p, []llvm.Value{zero, i}, "")
+		p = builder.CreatePtrToInt(p, uintptrType, "")
+		size := tm.target.TypeAllocSize(tm.ToLLVM(elem))
+		return p, llvm.ConstInt(uintptrType, size, false)
+	}
+
+	// Hash each field or element in turn.
+	algs.hash = llvm.AddFunction(tm.module, "", tm.hashAlgFunctionType)
+	h, p := algs.hash.Param(0), algs.hash.Param(2)
+	tm.forEachElement(builder, algs.hash, len(elems), n, func(i llvm.Value, j int) {
+		if blank(j) {
+			return
+		}
+		ep, size := elemptr(p, i, elems[j])
+		builder.CreateCall(elemalgs[j].hash, []llvm.Value{h, size, ep}, "")
+	})
+	builder.CreateRetVoid()
+
+	// Compare each field or element in turn, returning as soon as a pair
+	// is found to be unequal.
+	algs.equal = llvm.AddFunction(tm.module, "", tm.equalAlgFunctionType)
+	eq, a, b := algs.equal.Param(0), algs.equal.Param(2), algs.equal.Param(3)
+	tm.forEachElement(builder, algs.equal, len(elems), n, func(i llvm.Value, j int) {
+		if blank(j) {
+			return
+		}
+		ap, size := elemptr(a, i, elems[j])
+		bp, _ := elemptr(b, i, elems[j])
+		builder.CreateCall(elemalgs[j].equal, []llvm.Value{eq, size, ap, bp}, "")
+		next := llvm.AddBasicBlock(algs.equal, "")
+		unequal := llvm.AddBasicBlock(algs.equal, "")
+		builder.CreateCondBr(builder.CreateLoad(eq, ""), next, unequal)
+		builder.SetInsertPointAtEnd(unequal)
+		builder.CreateRetVoid()
+		builder.SetInsertPointAtEnd(next)
+	})
+	builder.CreateStore(llvm.ConstInt(llvm.Int1Type(), 1, false), eq)
+	builder.CreateRetVoid()
+	return algs
+}
+
+// forEachElement generates the body of an algorithm function, up to its
+// return, calling f for each field of a struct with nfields fields (if n
+// is negative), or each element of an array of n elements. The function f is passed the
+// index of the field or element, and the index of its type.
+func (tm *TypeMap) forEachElement(builder llvm.Builder, fn llvm.Value, nfields, n int, f func(i llvm.Value, j int)) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	builder.SetInsertPointAtEnd(entry)
+	if n < 0 {
+		for j := 0; j < nfields; j++ {
+			f(llvm.ConstInt(llvm.Int32Type(), uint64(j), false), j)
+		}
+		return
+	}
+
+	// Loop over the elements of an array.
+	uintptrType := tm.target.IntPtrType()
+	loop := llvm.AddBasicBlock(fn, "loop")
+	body := llvm.AddBasicBlock(fn, "body")
+	done := llvm.AddBasicBlock(fn, "done")
+	builder.CreateBr(loop)
+	builder.SetInsertPointAtEnd(loop)
+	i := builder.CreatePHI(uintptrType, "")
+	more := builder.CreateICmp(llvm.IntULT, i,
+		llvm.ConstInt(uintptrType, uint64(n), false), "")
+	builder.CreateCondBr(more, body, done)
+	builder.SetInsertPointAtEnd(body)
+	f(i, 0)
+	next := builder.CreateAdd(i, llvm.ConstInt(uintptrType, 1, false), "")
+	i.AddIncoming([]llvm.Value{llvm.ConstNull(uintptrType), next},
+		[]llvm.BasicBlock{entry, builder.GetInsertBlock()})
+	builder.CreateBr(loop)
+	done.MoveAfter(builder.GetInsertBlock())
+	builder.SetInsertPointAtEnd(done)
+}
+
+// vim: set ft=go :
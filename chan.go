@@ -0,0 +1,137 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// namedFunction returns the function with the specified name, adding a
+// declaration of the specified type to the module if it does not already
+// exist. Runtime functions are declared this way, and are resolved when the
+// runtime module is linked in.
+func (c *compiler) namedFunction(name string, fntype llvm.Type) llvm.Value {
+	fn := c.module.Module.NamedFunction(name)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(c.module.Module, name, fntype)
+	}
+	return fn
+}
+
+// makeChan creates a new channel of the specified type, with the given
+// buffer capacity. If capacity is nil, the channel is unbuffered.
+func (c *compiler) makeChan(typ types.Type, capacity Value) *LLVMValue {
+	chantyp := types.Underlying(typ).(*types.Chan)
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	chanmake := c.namedFunction("runtime.chanmake", funcType)
+
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(chantyp.Elt))
+	args := []llvm.Value{llvm.ConstInt(ptrType, elemsize, false), llvm.ConstNull(ptrType)}
+	if capacity != nil {
+		args[1] = capacity.Convert(types.Int).LLVMValue()
+	}
+	ch := c.builder.CreateCall(chanmake, args, "")
+	ch = c.builder.CreateIntToPtr(ch, c.types.ToLLVM(typ), "")
+	return c.NewLLVMValue(ch, typ)
+}
+
+// chanSend sends a value on a channel, blocking until the value has been
+// buffered or received.
+func (c *compiler) chanSend(ch *LLVMValue, elem Value) {
+	elttyp := types.Underlying(ch.Type()).(*types.Chan).Elt
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.VoidType(), paramTypes, false)
+	chansend := c.namedFunction("runtime.chansend", funcType)
+
+	// The runtime copies the element from memory, so store it on the stack.
+	elemptr := c.entryAlloca(c.types.ToLLVM(elttyp), "")
+	c.builder.CreateStore(elem.Convert(elttyp).LLVMValue(), elemptr)
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, ""),
+		c.builder.CreatePtrToInt(elemptr, ptrType, "")}
+	c.createCall(chansend, args)
+}
+
+// chanRecv receives a value from a channel, blocking until a value is
+// available or the channel is closed. If commaOk is true, then the result
+// is a {value, ok} pair, where ok is false if the channel was closed and
+// drained.
+func (c *compiler) chanRecv(ch *LLVMValue, commaOk bool) *LLVMValue {
+	elttyp := types.Underlying(ch.Type()).(*types.Chan).Elt
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.Int1Type(), paramTypes, false)
+	chanrecv := c.namedFunction("runtime.chanrecv", funcType)
+
+	// The receive slot is allocated in the entry block, so that receives
+	// in a loop (such as a range over a channel) reuse it.
+	elemptr := c.entryAlloca(c.types.ToLLVM(elttyp), "")
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, ""),
+		c.builder.CreatePtrToInt(elemptr, ptrType, "")}
+	ok := c.builder.CreateCall(chanrecv, args, "")
+	elem := c.builder.CreateLoad(elemptr, "")
+	value := c.NewLLVMValue(elem, elttyp)
+	if !commaOk {
+		return value
+	}
+	return c.commaOkValue(value, ok)
+}
+
+// chanClose closes a channel.
+func (c *compiler) chanClose(ch *LLVMValue) {
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType}
+	funcType := llvm.FunctionType(llvm.VoidType(), paramTypes, false)
+	chanclose := c.namedFunction("runtime.chanclose", funcType)
+	args := []llvm.Value{c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")}
+	c.createCall(chanclose, args)
+}
+
+// chanLenCap returns the number of buffered elements in a channel (if
+// fn is "len"), or the channel's buffer capacity (if fn is "cap").
+func (c *compiler) chanLenCap(ch *LLVMValue, fn string) *LLVMValue {
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	f := c.namedFunction("runtime.chan"+fn, funcType)
+	args := []llvm.Value{c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")}
+	result := c.builder.CreateCall(f, args, "")
+	return c.NewLLVMValue(result, types.Int)
+}
+
+func (c *compiler) VisitClose(expr *ast.CallExpr) Value {
+	if len(expr.Args) != 1 {
+		panic("Expecting exactly one argument to close")
+	}
+	ch := c.VisitExpr(expr.Args[0]).(*LLVMValue)
+	c.chanClose(ch)
+	return nil
+}
+
+// vim: set ft=go :
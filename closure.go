@@ -0,0 +1,185 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// freeVars returns the variables of enclosing functions which are referred
+// to within a function literal, in order of first reference.
+func (c *compiler) freeVars(lit *ast.FuncLit) []*ast.Object {
+	var vars []*ast.Object
+	seen := make(map[*ast.Object]bool)
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			// The selector is a field or method name, or a qualified
+			// identifier, so only the operand may refer to a variable.
+			ast.Inspect(node.X, visit)
+			return false
+		case *ast.Ident:
+			obj := node.Obj
+			if obj == nil || obj.Kind != ast.Var || seen[obj] {
+				break
+			}
+			if _, isglobal := c.pkgmap[obj]; isglobal {
+				break
+			}
+			if pos := obj.Pos(); pos.IsValid() && (pos < lit.Pos() || pos >= lit.End()) {
+				seen[obj] = true
+				vars = append(vars, obj)
+			}
+		}
+		return true
+	}
+	ast.Inspect(lit.Body, visit)
+	return vars
+}
+
+// findCapturedVars records the variables which are captured by function
+// literals in the package.
+func (c *compiler) findCapturedVars(pkg *ast.Package) {
+	c.captured = make(map[*ast.Object]bool)
+	for _, file := range pkg.Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if lit, ok := node.(*ast.FuncLit); ok {
+				for _, obj := range c.freeVars(lit) {
+					c.captured[obj] = true
+				}
+			}
+			return true
+		})
+	}
+}
+
+// allocVar allocates storage for a local variable. Variables captured by
+// function literals are allocated on the heap, so that they may outlive
+// the frame of the function that declares them; others are allocated on
+// the stack.
+func (c *compiler) allocVar(obj *ast.Object, typ llvm.Type) llvm.Value {
+	name := ""
+	if obj != nil {
+		name = obj.Name
+		if c.captured[obj] {
+			return c.builder.CreateMalloc(typ, name)
+		}
+	}
+	return c.builder.CreateAlloca(typ, name)
+}
+
+// entryAlloca allocates a stack slot in the entry block of the function
+// being compiled. Temporaries needed by an operation are allocated this
+// way so that they are reused each time the operation is executed, rather
+// than growing the stack when the operation is in a loop.
+func (c *compiler) entryAlloca(typ llvm.Type, name string) llvm.Value {
+	entry := c.builder.GetInsertBlock().Parent().EntryBasicBlock()
+	builder := llvm.NewBuilder()
+	defer builder.Dispose()
+	if first := entry.FirstInstruction(); first.IsNil() {
+		builder.SetInsertPointAtEnd(entry)
+	} else {
+		builder.SetInsertPointBefore(first)
+	}
+	return builder.CreateAlloca(typ, name)
+}
+
+// callFunc generates a call to a func value, returning the result. If the
+// func value is a closure, i.e. its context is non-nil, then the context is
+// passed as an additional first argument.
+func (c *compiler) callFunc(fn *LLVMValue, args []llvm.Value) llvm.Value {
+	fn_value := fn.LLVMValue()
+	fnptr := c.builder.CreateExtractValue(fn_value, 0, "")
+
+	// Functions and methods have a nil context.
+	if fn_value.IsConstant() || fn.Type().(*types.Func).Recv != nil {
+		return c.createCall(fnptr, args)
+	}
+
+	currBlock := c.builder.GetInsertBlock()
+	endBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	endBlock.MoveAfter(currBlock)
+	ctxBlock := llvm.InsertBasicBlock(endBlock, "")
+	nilctxBlock := llvm.InsertBasicBlock(ctxBlock, "")
+	ctx := c.builder.CreateExtractValue(fn_value, 1, "")
+	c.builder.CreateCondBr(c.builder.CreateIsNull(ctx, ""), nilctxBlock, ctxBlock)
+
+	c.builder.SetInsertPointAtEnd(nilctxBlock)
+	nilctxResult := c.createCall(fnptr, args)
+	nilctxBlock = c.builder.GetInsertBlock()
+	c.builder.CreateBr(endBlock)
+
+	c.builder.SetInsertPointAtEnd(ctxBlock)
+	fn_type := fnptr.Type().ElementType()
+	param_types := append([]llvm.Type{ctx.Type()}, fn_type.ParamTypes()...)
+	ctx_fn_type := llvm.FunctionType(fn_type.ReturnType(), param_types, false)
+	ctx_fnptr := c.builder.CreateBitCast(fnptr, llvm.PointerType(ctx_fn_type, 0), "")
+	ctxResult := c.createCall(ctx_fnptr, append([]llvm.Value{ctx}, args...))
+	ctxBlock = c.builder.GetInsertBlock()
+	c.builder.CreateBr(endBlock)
+
+	c.builder.SetInsertPointAtEnd(endBlock)
+	if fn_type.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		return nilctxResult
+	}
+	result := c.builder.CreatePHI(fn_type.ReturnType(), "")
+	result.AddIncoming([]llvm.Value{nilctxResult, ctxResult},
+		[]llvm.BasicBlock{nilctxBlock, ctxBlock})
+	return result
+}
+
+// makeClosure creates a func value for a function literal which refers to
+// the specified variables of enclosing functions. The context is a
+// heap-allocated structure holding pointers to the variables.
+func (c *compiler) makeClosure(fn llvm.Value, fn_type *types.Func, vars []*ast.Object) Value {
+	fn_pair_type := c.types.ToLLVM(fn_type)
+	fn_value := llvm.ConstNull(fn_pair_type)
+	if len(vars) == 0 {
+		fn_value = llvm.ConstInsertValue(fn_value, fn, []uint32{0})
+		return c.NewLLVMValue(fn_value, fn_type)
+	}
+
+	ctx := c.builder.CreateMalloc(closureContextType(fn), "")
+	for i, obj := range vars {
+		ptr := obj.Data.(*LLVMValue).pointer.LLVMValue()
+		c.builder.CreateStore(ptr, c.builder.CreateStructGEP(ctx, i, ""))
+	}
+	element_types := fn_pair_type.StructElementTypes()
+	fnptr := llvm.ConstBitCast(fn, element_types[0])
+	ctx = c.builder.CreateBitCast(ctx, element_types[1], "")
+	fn_value = c.builder.CreateInsertValue(fn_value, fnptr, 0, "")
+	fn_value = c.builder.CreateInsertValue(fn_value, ctx, 1, "")
+	return c.NewLLVMValue(fn_value, fn_type)
+}
+
+// closureContextType returns the type of the context structure of a
+// closure function, which is pointed to by its first parameter.
+func closureContextType(fn llvm.Value) llvm.Type {
+	return fn.Type().ElementType().ParamTypes()[0].ElementType()
+}
+
+// vim: set ft=go :
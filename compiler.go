@@ -58,17 +58,60 @@ type compiler struct {
 	targetArch string
 	targetOs   string
 	target     llvm.TargetData
-	functions  []Value
+	functions  []*function
 	initfuncs  []Value
 	pkg        *ast.Package
 	fileset    *token.FileSet
 	filescope  *ast.Scope
 	scope      *ast.Scope
 	pkgmap     map[*ast.Object]string
+	captured   map[*ast.Object]bool              // variables captured by closures
+	shims      map[interfaceMethodKey]llvm.Value // interface method shims
+	itabs      map[itabKey]llvm.Value            // interface method tables
 	types      *TypeMap
 	logger     *log.Logger
 }
 
+// function holds the state of a function whose body is being compiled.
+type function struct {
+	Value
+
+	// deferlist is a stack slot holding the head of the function's list of
+	// deferred calls, or nil if the function contains no defer statements.
+	deferlist llvm.Value
+
+	// unwindblock is the landing pad for calls made by a function with
+	// deferred calls, or nil if the function contains no defer statements.
+	unwindblock llvm.BasicBlock
+
+	// results holds pointers to the variables holding the function's
+	// results, if they are named or the function contains defer
+	// statements; otherwise it is nil.
+	results []llvm.Value
+
+	// canrecover is true if the function was called directly by a
+	// deferred call, and so may recover a panic. It is nil if the function
+	// does not call recover.
+	canrecover llvm.Value
+
+	// branchTargets is the stack of statements enclosing the current
+	// statement that may be the target of a break or continue statement.
+	branchTargets []branchTarget
+
+	// labels maps each label to the block beginning its statement, and
+	// label holds the label of the statement currently being compiled.
+	labels map[*ast.Object]llvm.BasicBlock
+	label  *ast.Object
+}
+
+// branchTarget records the blocks that a break or continue statement
+// within a "for", "switch" or "select" statement branches to.
+type branchTarget struct {
+	label         *ast.Object
+	breakBlock    llvm.BasicBlock
+	continueBlock llvm.BasicBlock // nil for "switch" and "select"
+}
+
 func (c *compiler) LookupObj(name string) *ast.Object {
 	// TODO check for qualified identifiers (x.y), and short-circuit the
 	// lookup.
@@ -297,11 +340,15 @@ func (compiler *compiler) Compile(fset *token.FileSet,
 			//err = e.(error)
 		}
 	}()
-	compiler.types = NewTypeMap(compiler.module.Module, compiler.target, exprTypes)
-
 	// Create a mapping from objects back to packages, so we can create the
 	// appropriate symbol names.
 	compiler.pkgmap = createPackageMap(pkg)
+	compiler.types = NewTypeMap(compiler.module.Module, compiler.target,
+		compiler, exprTypes, compiler.pkgmap, pkg.Name)
+
+	// Find the variables captured by closures, which must be allocated on
+	// the heap.
+	compiler.findCapturedVars(pkg)
 
 	// Compile each file in the package.
 	for _, file := range pkg.Files {
@@ -0,0 +1,192 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/token"
+)
+
+// complexParts returns the real and imaginary parts of a complex value.
+func (c *compiler) complexParts(v Value) (re, im llvm.Value) {
+	value := v.LLVMValue()
+	re = c.builder.CreateExtractValue(value, 0, "")
+	im = c.builder.CreateExtractValue(value, 1, "")
+	return
+}
+
+// makeComplex creates a value of the complex type typ from its real and
+// imaginary parts.
+func (c *compiler) makeComplex(re, im llvm.Value, typ types.Type) *LLVMValue {
+	result := llvm.Undef(c.types.ToLLVM(typ))
+	result = c.builder.CreateInsertValue(result, re, 0, "")
+	result = c.builder.CreateInsertValue(result, im, 1, "")
+	return c.NewLLVMValue(result, typ)
+}
+
+// complexFloatType returns the type of the parts of a complex type.
+func complexFloatType(typ types.Type) types.Type {
+	for name, isname := typ.(*types.Name); isname; name, isname = typ.(*types.Name) {
+		typ = name.Underlying
+	}
+	if typ.(*types.Basic).Kind == types.Complex64Kind {
+		return types.Float32
+	}
+	return types.Float64
+}
+
+// complexBinaryOp compiles an arithmetic or equality operation on complex
+// operands. Division is performed by runtime.complex128div, as in gc;
+// complex64 operands are converted to complex128 and back.
+func (lhs *LLVMValue) complexBinaryOp(op token.Token, rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	a, bi := c.complexParts(lhs)
+	cr, di := c.complexParts(rhs)
+	var re, im llvm.Value
+	switch op {
+	case token.ADD:
+		re = b.CreateFAdd(a, cr, "")
+		im = b.CreateFAdd(bi, di, "")
+	case token.SUB:
+		re = b.CreateFSub(a, cr, "")
+		im = b.CreateFSub(bi, di, "")
+	case token.MUL:
+		// (a+bi)(c+di) = (ac-bd) + (ad+bc)i
+		re = b.CreateFSub(b.CreateFMul(a, cr, ""), b.CreateFMul(bi, di, ""), "")
+		im = b.CreateFAdd(b.CreateFMul(a, di, ""), b.CreateFMul(bi, cr, ""), "")
+	case token.QUO:
+		f64 := llvm.DoubleType()
+		args := []llvm.Value{a, bi, cr, di}
+		if a.Type().TypeKind() == llvm.FloatTypeKind {
+			for i, arg := range args {
+				args[i] = b.CreateFPExt(arg, f64, "")
+			}
+		}
+		resultType := llvm.StructType([]llvm.Type{f64, f64}, false)
+		paramTypes := []llvm.Type{f64, f64, f64, f64}
+		funcType := llvm.FunctionType(resultType, paramTypes, false)
+		complex128div := c.namedFunction("runtime.complex128div", funcType)
+		result := c.createCall(complex128div, args)
+		re = b.CreateExtractValue(result, 0, "")
+		im = b.CreateExtractValue(result, 1, "")
+		if a.Type().TypeKind() == llvm.FloatTypeKind {
+			re = b.CreateFPTrunc(re, a.Type(), "")
+			im = b.CreateFPTrunc(im, a.Type(), "")
+		}
+	case token.EQL:
+		reEqual := b.CreateFCmp(llvm.FloatOEQ, a, cr, "")
+		imEqual := b.CreateFCmp(llvm.FloatOEQ, bi, di, "")
+		return c.NewLLVMValue(b.CreateAnd(reEqual, imEqual, ""), types.Bool)
+	default:
+		panic(fmt.Sprint("Unimplemented operator: ", op))
+	}
+	return c.makeComplex(re, im, lhs.typ)
+}
+
+// convertComplex converts a complex value to the complex type typ.
+func (c *compiler) convertComplex(v *LLVMValue, typ types.Type) *LLVMValue {
+	re, im := c.complexParts(v)
+	srcFloatType := complexFloatType(v.Type())
+	dstFloatType := complexFloatType(typ)
+	re = c.NewLLVMValue(re, srcFloatType).Convert(dstFloatType).LLVMValue()
+	im = c.NewLLVMValue(im, srcFloatType).Convert(dstFloatType).LLVMValue()
+	return c.makeComplex(re, im, typ)
+}
+
+// VisitComplex compiles a call to complex, which constructs a complex value
+// from floating point real and imaginary parts.
+func (c *compiler) VisitComplex(expr *ast.CallExpr) Value {
+	re := c.VisitExpr(expr.Args[0])
+	im := c.VisitExpr(expr.Args[1])
+	if re, ok := re.(ConstValue); ok {
+		if im, ok := im.(ConstValue); ok {
+			// re + im*1i. If either part is typed, then so is the
+			// result: complex64 for float32 parts, else complex128.
+			i := types.MakeConst(token.IMAG, "1i")
+			value := re.Const.BinaryOp(token.ADD, im.Const.BinaryOp(token.MUL, i))
+			typ := types.Complex128.Underlying
+			for _, part := range []ConstValue{re, im} {
+				if _, typed := part.typ.(*types.Name); typed {
+					typ = types.Complex128
+					if c.types.ToLLVM(part.typ).TypeKind() == llvm.FloatTypeKind {
+						typ = types.Complex64
+						break
+					}
+				}
+			}
+			return ConstValue{*value, c, typ}
+		}
+	}
+
+	// An untyped constant part takes the type of the other part.
+	floatType := re.Type()
+	if _, ok := re.(ConstValue); ok {
+		floatType = im.Type()
+	}
+	typ := types.Complex128
+	if c.types.ToLLVM(floatType).TypeKind() == llvm.FloatTypeKind {
+		typ = types.Complex64
+	}
+	floatType = complexFloatType(typ)
+	return c.makeComplex(
+		re.Convert(floatType).LLVMValue(),
+		im.Convert(floatType).LLVMValue(), typ)
+}
+
+// VisitReal compiles a call to real, which returns the real part of a
+// complex value.
+func (c *compiler) VisitReal(expr *ast.CallExpr) Value {
+	return c.complexPart(expr, 0)
+}
+
+// VisitImag compiles a call to imag, which returns the imaginary part of a
+// complex value.
+func (c *compiler) VisitImag(expr *ast.CallExpr) Value {
+	return c.complexPart(expr, 1)
+}
+
+func (c *compiler) complexPart(expr *ast.CallExpr, index int) Value {
+	value := c.VisitExpr(expr.Args[0])
+	if value, ok := value.(ConstValue); ok {
+		re, im := value.Const.Complex()
+		part := re
+		if index == 1 {
+			part = im
+		}
+		// A typed constant's part is typed: float32 for complex64,
+		// else float64.
+		typ := types.Float64.Underlying
+		if _, typed := value.typ.(*types.Name); typed {
+			typ = complexFloatType(value.typ)
+		}
+		return ConstValue{types.Const{Val: part}, c, typ}
+	}
+	part := c.builder.CreateExtractValue(value.LLVMValue(), index, "")
+	return c.NewLLVMValue(part, complexFloatType(value.Type()))
+}
+
+// vim: set ft=go :
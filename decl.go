@@ -54,13 +54,23 @@ func (c *compiler) VisitFuncProtoDecl(f *ast.FuncDecl) Value {
 		}
 	}
 
-	llvm_fn_type := c.types.ToLLVM(fn_type).ElementType()
-	fn := llvm.AddFunction(c.module.Module, fn_name, llvm_fn_type)
+	fn_pair_type := c.types.ToLLVM(fn_type)
+	llvm_fn_type := fn_pair_type.StructElementTypes()[0].ElementType()
+	var fn llvm.Value
+	if fn_name != "" {
+		// The function may already have been declared by a reference
+		// from generated code, e.g. a runtime algorithm function.
+		fn = c.namedFunction(fn_name, llvm_fn_type)
+	} else {
+		fn = llvm.AddFunction(c.module.Module, fn_name, llvm_fn_type)
+	}
 	if exported {
 		fn.SetLinkage(llvm.ExternalLinkage)
 	}
 
-	result := c.NewLLVMValue(fn, fn_type)
+	// The function's value is a func value with a nil context.
+	fn_value := llvm.ConstInsertValue(llvm.ConstNull(fn_pair_type), fn, []uint32{0})
+	result := c.NewLLVMValue(fn_value, fn_type)
 	if f.Name.Obj != nil {
 		f.Name.Obj.Data = result
 		f.Name.Obj.Type = fn_type
@@ -80,55 +90,33 @@ func (c *compiler) VisitFuncDecl(f *ast.FuncDecl) Value {
 	}
 
 	fn_type := fn.Type().(*types.Func)
-	llvm_fn := fn.LLVMValue()
+	llvm_fn := llvm.ConstExtractValue(fn.LLVMValue(), []uint32{0})
 
 	entry := llvm.AddBasicBlock(llvm_fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
+	c.bindParams(fn_type, llvm_fn, 0)
 
-	// Bind receiver, arguments and return values to their identifiers/objects.
-	// We'll store each parameter on the stack so they're addressable.
-	param_i := 0
-	if f.Recv != nil {
-		param_0 := llvm_fn.Param(0)
-		recv_obj := fn_type.Recv
-		recv_type := recv_obj.Type.(types.Type)
-		stack_value := c.builder.CreateAlloca(
-			c.types.ToLLVM(recv_type), recv_obj.Name)
-		c.builder.CreateStore(param_0, stack_value)
-		value := c.NewLLVMValue(stack_value, &types.Pointer{Base: recv_type})
-		recv_obj.Data = value.makePointee()
-		param_i++
-	}
-	for _, param := range fn_type.Params {
-		name := param.Name
-		if name != "_" {
-			param_type := param.Type.(types.Type)
-			param_value := llvm_fn.Param(param_i)
-			stack_value := c.builder.CreateAlloca(c.types.ToLLVM(param_type), name)
-			c.builder.CreateStore(param_value, stack_value)
-			value := c.NewLLVMValue(stack_value,
-				&types.Pointer{Base: param_type})
-			param.Data = value.makePointee()
-		}
-		param_i++
-	}
-
-	c.functions = append(c.functions, fn)
+	c.pushFunction(c.NewLLVMValue(llvm_fn, fn_type), f.Body)
 	if f.Body != nil {
 		c.VisitBlockStmt(f.Body)
 	}
-	c.functions = c.functions[0 : len(c.functions)-1]
 
-	last_block := llvm_fn.LastBasicBlock()
-	lasti := last_block.LastInstruction()
+	lasti := c.builder.GetInsertBlock().LastInstruction()
 	if lasti.IsNil() || lasti.IsATerminatorInst().IsNil() {
-		// Assume nil return type, AST should be checked first.
-		c.builder.CreateRetVoid()
+		if len(fn_type.Results) > 0 {
+			// All paths return; this block is unreachable (e.g. the
+			// resume block of an if/else whose branches both return).
+			c.builder.CreateUnreachable()
+		} else {
+			c.runDefers()
+			c.builder.CreateRetVoid()
+		}
 	}
+	c.functions = c.functions[0 : len(c.functions)-1]
 
 	// Is it an 'init' function? Then record it.
 	if f.Name.String() == "init" {
-		c.initfuncs = append(c.initfuncs, fn)
+		c.initfuncs = append(c.initfuncs, c.NewLLVMValue(llvm_fn, fn_type))
 	} else {
 		//if obj != nil {
 		//    obj.Data = fn
@@ -137,6 +125,57 @@ func (c *compiler) VisitFuncDecl(f *ast.FuncDecl) Value {
 	return fn
 }
 
+// bindParams binds the receiver and parameters of a function to the LLVM
+// function's parameters, beginning with the parameter at index param_i.
+// Each parameter is stored in a new variable, so that it is addressable.
+func (c *compiler) bindParams(fn_type *types.Func, llvm_fn llvm.Value, param_i int) {
+	params := fn_type.Params
+	if fn_type.Recv != nil {
+		params = append([]*ast.Object{fn_type.Recv}, params...)
+	}
+	for _, param := range params {
+		if param.Name != "_" {
+			param_type := param.Type.(types.Type)
+			param_value := llvm_fn.Param(param_i)
+			stack_value := c.allocVar(param, c.types.ToLLVM(param_type))
+			c.builder.CreateStore(param_value, stack_value)
+			value := c.NewLLVMValue(stack_value,
+				&types.Pointer{Base: param_type})
+			param.Data = value.makePointee()
+		}
+		param_i++
+	}
+}
+
+// bindResults allocates a variable for each of a function's results, if
+// they are named or the function has deferred calls, which may run after a
+// return statement has set the results. Named results are bound to their
+// variables. The variables are initialised to the zero value, and pointers
+// to them are returned.
+func (c *compiler) bindResults(fn_type *types.Func, hasdefer bool) []llvm.Value {
+	if len(fn_type.Results) == 0 {
+		return nil
+	}
+	if fn_type.Results[0].Name == "" && !hasdefer {
+		return nil
+	}
+	results := make([]llvm.Value, len(fn_type.Results))
+	for i, result := range fn_type.Results {
+		result_type := result.Type.(types.Type)
+		llvm_type := c.types.ToLLVM(result_type)
+		if result.Name == "" || result.Name == "_" {
+			results[i] = c.allocVar(nil, llvm_type)
+		} else {
+			results[i] = c.allocVar(result, llvm_type)
+			value := c.NewLLVMValue(results[i],
+				&types.Pointer{Base: result_type})
+			result.Data = value.makePointee()
+		}
+		c.builder.CreateStore(llvm.ConstNull(llvm_type), results[i])
+	}
+	return results
+}
+
 func isArray(t types.Type) bool {
 	_, isarray := t.(*types.Array)
 	return isarray
@@ -160,7 +199,7 @@ func (c *compiler) createGlobal(e ast.Expr,
 		defer c.builder.SetInsertPointAtEnd(block)
 	}
 	fn_type := new(types.Func)
-	llvm_fn_type := c.types.ToLLVM(fn_type).ElementType()
+	llvm_fn_type := c.types.ToLLVM(fn_type).StructElementTypes()[0].ElementType()
 	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
 	entry := llvm.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
@@ -208,6 +247,54 @@ func (c *compiler) createGlobal(e ast.Expr,
 	return g
 }
 
+// createGlobals creates the globals for a package-level declaration of
+// several variables with a single multi-valued initialiser, as in
+// "var v, ok = m[k]". The initialiser is evaluated once, in a constructor
+// function which stores each of its values in the corresponding global.
+func (c *compiler) createGlobals(valspec *ast.ValueSpec, t types.Type) {
+	// The declaration may already have been visited when resolving one of
+	// its names.
+	for _, name := range valspec.Names {
+		if _, isvalue := (name.Obj.Data).(Value); isvalue {
+			return
+		}
+	}
+
+	if block := c.builder.GetInsertBlock(); !block.IsNil() {
+		defer c.builder.SetInsertPointAtEnd(block)
+	}
+	fn_type := new(types.Func)
+	llvm_fn_type := c.types.ToLLVM(fn_type).StructElementTypes()[0].ElementType()
+	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+
+	values := c.evalMultiValue(valspec.Values[0])
+	for i, name := range valspec.Names {
+		value, typ := values[i], t
+		if typ == nil {
+			typ = value.Type()
+		} else {
+			value = value.Convert(typ)
+		}
+		gv := llvm.AddGlobal(c.module.Module, c.types.ToLLVM(typ), name.Name)
+		gv.SetInitializer(llvm.ConstNull(c.types.ToLLVM(typ)))
+		if !name.IsExported() {
+			gv.SetLinkage(llvm.InternalLinkage)
+		}
+		c.builder.CreateStore(value.LLVMValue(), gv)
+		if name.Name != "_" {
+			g := c.NewLLVMValue(gv, &types.Pointer{Base: typ})
+			if !isArray(typ) {
+				g = g.makePointee()
+			}
+			name.Obj.Data = g
+		}
+	}
+	c.builder.CreateRetVoid()
+	c.initfuncs = append(c.initfuncs, c.NewLLVMValue(fn, fn_type))
+}
+
 func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 	var value_type types.Type
 	if valspec.Type != nil {
@@ -219,6 +306,17 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 		iota_obj.Data = data
 	}(iota_obj.Data)
 
+	// A single expression yielding multiple values, as in
+	// "var v, ok = m[k]", is evaluated once for all of the names.
+	var values []Value
+	if !isconst && len(valspec.Names) > 1 && len(valspec.Values) == 1 {
+		if len(c.functions) == 0 {
+			c.createGlobals(valspec, value_type)
+			return
+		}
+		values = c.evalMultiValue(valspec.Values[0])
+	}
+
 	for i, name_ := range valspec.Names {
 		// We may resolve constants in the process of resolving others.
 		obj := name_.Obj
@@ -255,27 +353,29 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 			if !ispackagelevel {
 				// Visit the expression.
 				var init_ Value
-				if expr != nil {
+				if values != nil {
+					init_ = values[i]
+				} else if expr != nil {
 					init_ = c.VisitExpr(expr)
-					if value_type == nil {
-						value_type = init_.Type()
-					}
+				}
+				var_type := value_type
+				if var_type == nil {
+					var_type = init_.Type()
 				}
 
 				// The variable should be allocated on the stack if it's
 				// declared inside a function.
 				var llvm_init llvm.Value
-				stack_value := c.builder.CreateAlloca(
-					c.types.ToLLVM(value_type), name)
+				stack_value := c.allocVar(name_.Obj, c.types.ToLLVM(var_type))
 				if init_ == nil {
 					// If no initialiser was specified, set it to the
 					// zero value.
-					llvm_init = llvm.ConstNull(c.types.ToLLVM(value_type))
+					llvm_init = llvm.ConstNull(c.types.ToLLVM(var_type))
 				} else {
-					llvm_init = init_.Convert(value_type).LLVMValue()
+					llvm_init = init_.Convert(var_type).LLVMValue()
 				}
 				c.builder.CreateStore(llvm_init, stack_value)
-				llvm_value := c.NewLLVMValue(stack_value, &types.Pointer{Base: value_type})
+				llvm_value := c.NewLLVMValue(stack_value, &types.Pointer{Base: var_type})
 				value = llvm_value.makePointee()
 			} else { // ispackagelevel
 				// Set the initialiser. If it's a non-const value, then
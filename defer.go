@@ -0,0 +1,239 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// containsDefer reports whether a function body contains a defer statement,
+// excluding those within nested function literals.
+func containsDefer(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.DeferStmt:
+			found = true
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// pushFunction records fn as the function whose body is being compiled.
+// The function's results are bound to variables if necessary. If the body
+// contains defer statements, then a stack slot is allocated in the current
+// (entry) block to hold the function's list of deferred calls, and a landing
+// pad is created to run them if a panic unwinds the function.
+func (c *compiler) pushFunction(fn Value, body *ast.BlockStmt) {
+	f := &function{Value: fn}
+	if body != nil {
+		hasdefer := containsDefer(body)
+		f.results = c.bindResults(fn.Type().(*types.Func), hasdefer)
+		if containsRecover(body) {
+			f.canrecover = c.canRecover(fn.LLVMValue())
+		}
+		if hasdefer {
+			i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+			f.deferlist = c.builder.CreateAlloca(i8ptr, "deferlist")
+			c.builder.CreateStore(llvm.ConstNull(i8ptr), f.deferlist)
+			f.unwindblock = c.makeLandingPad(f)
+		}
+	}
+	c.functions = append(c.functions, f)
+}
+
+// rundefersFunction returns runtime.rundefers, which runs the deferred
+// calls in a defer list in last-in first-out order.
+func (c *compiler) rundefersFunction() llvm.Value {
+	ptrType := c.target.IntPtrType()
+	funcType := llvm.FunctionType(llvm.VoidType(), []llvm.Type{ptrType}, false)
+	return c.namedFunction("runtime.rundefers", funcType)
+}
+
+// runDefers generates a call to runtime.rundefers for the current
+// function's defer list. If a deferred call panics, the remaining deferred
+// calls are run by the function's landing pad.
+func (c *compiler) runDefers() {
+	f := c.functions[len(c.functions)-1]
+	if f.deferlist.IsNil() {
+		return
+	}
+	ptrType := c.target.IntPtrType()
+	args := []llvm.Value{c.builder.CreatePtrToInt(f.deferlist, ptrType, "")}
+	c.createCall(c.rundefersFunction(), args)
+}
+
+// VisitDeferStmt evaluates the function value and arguments of a deferred
+// call, and pushes a record of the call onto the current function's defer
+// list. The record begins with the header expected by the runtime (the next
+// record, and a "thunk" function which takes the record and performs the
+// call), followed by the function value and arguments.
+func (c *compiler) VisitDeferStmt(stmt *ast.DeferStmt) {
+	if ident, ok := stmt.Call.Fun.(*ast.Ident); ok {
+		if types.Universe.Lookup(ident.Name) == ident.Obj {
+			c.deferBuiltin(stmt.Call)
+			return
+		}
+	}
+	fn := c.visitCallee(stmt.Call.Fun).(*LLVMValue)
+	fn_value := fn.LLVMValue()
+	args := c.evalCallArgs(fn, stmt.Call.Args)
+
+	// The thunk is stored as a func value with a nil context.
+	ptrType := c.target.IntPtrType()
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	thunkType := llvm.FunctionType(llvm.VoidType(), []llvm.Type{ptrType}, false)
+	thunkPairType := llvm.StructType(
+		[]llvm.Type{llvm.PointerType(thunkType, 0), i8ptr}, false)
+	fieldTypes := []llvm.Type{i8ptr, thunkPairType, fn_value.Type()}
+	for _, arg := range args {
+		fieldTypes = append(fieldTypes, arg.Type())
+	}
+	recordType := llvm.StructType(fieldTypes, false)
+	thunk := llvm.AddFunction(c.module.Module, "", thunkType)
+
+	// Fill in the record and push it onto the defer list.
+	deferlist := c.functions[len(c.functions)-1].deferlist
+	record := c.builder.CreateMalloc(recordType, "")
+	next := c.builder.CreateLoad(deferlist, "")
+	c.builder.CreateStore(next, c.builder.CreateStructGEP(record, 0, ""))
+	thunkPair := llvm.ConstInsertValue(
+		llvm.ConstNull(thunkPairType), thunk, []uint32{0})
+	c.builder.CreateStore(thunkPair, c.builder.CreateStructGEP(record, 1, ""))
+	c.builder.CreateStore(fn_value, c.builder.CreateStructGEP(record, 2, ""))
+	for i, arg := range args {
+		c.builder.CreateStore(arg, c.builder.CreateStructGEP(record, i+3, ""))
+	}
+	c.builder.CreateStore(c.builder.CreateBitCast(record, i8ptr, ""), deferlist)
+
+	// When done, return to where we were.
+	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+
+	// Generate the thunk, which loads the function and arguments from the
+	// record and calls the function.
+	entry := llvm.AddBasicBlock(thunk, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.pushFunction(c.NewLLVMValue(thunk, &types.Func{}), nil)
+	record = c.builder.CreateIntToPtr(
+		thunk.Param(0), llvm.PointerType(recordType, 0), "")
+	fn_value = c.builder.CreateLoad(c.builder.CreateStructGEP(record, 2, ""), "")
+	for i := range args {
+		args[i] = c.builder.CreateLoad(c.builder.CreateStructGEP(record, i+3, ""), "")
+	}
+	c.deferredCall(fn_value)
+	c.callFunc(c.NewLLVMValue(fn_value, fn.Type()), args)
+	c.builder.CreateRetVoid()
+	c.functions = c.functions[0 : len(c.functions)-1]
+}
+
+// deferredCall generates a call to runtime.deferredcall, which records
+// that the function of the func value fn is about to be called by a
+// deferred call, and so may recover a panic.
+func (c *compiler) deferredCall(fn llvm.Value) {
+	ptrType := c.target.IntPtrType()
+	funcType := llvm.FunctionType(llvm.VoidType(), []llvm.Type{ptrType}, false)
+	deferredcall := c.namedFunction("runtime.deferredcall", funcType)
+	fnptr := c.builder.CreateExtractValue(fn, 0, "")
+	args := []llvm.Value{c.builder.CreatePtrToInt(fnptr, ptrType, "")}
+	c.builder.CreateCall(deferredcall, args, "")
+}
+
+// deferBuiltin pushes a record for a deferred call to a builtin function
+// onto the current function's defer list. The arguments are evaluated now
+// and stored in the record; constant and nil arguments are left in the call, as
+// they may be evaluated anywhere. The thunk compiles the builtin call with the
+// remaining arguments bound to the values loaded from the record.
+func (c *compiler) deferBuiltin(call *ast.CallExpr) {
+	var values []*LLVMValue
+	args := make([]ast.Expr, len(call.Args))
+	for i, arg := range call.Args {
+		value := c.VisitExpr(arg)
+		switch value.(type) {
+		case ConstValue, NilValue:
+			args[i] = arg
+		default:
+			values = append(values, c.NewLLVMValue(value.LLVMValue(), value.Type()))
+		}
+	}
+
+	ptrType := c.target.IntPtrType()
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	thunkType := llvm.FunctionType(llvm.VoidType(), []llvm.Type{ptrType}, false)
+	thunkPairType := llvm.StructType(
+		[]llvm.Type{llvm.PointerType(thunkType, 0), i8ptr}, false)
+	fieldTypes := []llvm.Type{i8ptr, thunkPairType}
+	for _, value := range values {
+		fieldTypes = append(fieldTypes, value.LLVMValue().Type())
+	}
+	recordType := llvm.StructType(fieldTypes, false)
+	thunk := llvm.AddFunction(c.module.Module, "", thunkType)
+
+	// Fill in the record and push it onto the defer list.
+	deferlist := c.functions[len(c.functions)-1].deferlist
+	record := c.builder.CreateMalloc(recordType, "")
+	next := c.builder.CreateLoad(deferlist, "")
+	c.builder.CreateStore(next, c.builder.CreateStructGEP(record, 0, ""))
+	thunkPair := llvm.ConstInsertValue(
+		llvm.ConstNull(thunkPairType), thunk, []uint32{0})
+	c.builder.CreateStore(thunkPair, c.builder.CreateStructGEP(record, 1, ""))
+	for i, value := range values {
+		c.builder.CreateStore(value.LLVMValue(),
+			c.builder.CreateStructGEP(record, i+2, ""))
+	}
+	c.builder.CreateStore(c.builder.CreateBitCast(record, i8ptr, ""), deferlist)
+
+	// When done, return to where we were.
+	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+
+	// Generate the thunk, which loads the arguments from the record and
+	// compiles the builtin call.
+	entry := llvm.AddBasicBlock(thunk, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.pushFunction(c.NewLLVMValue(thunk, &types.Func{}), nil)
+	record = c.builder.CreateIntToPtr(
+		thunk.Param(0), llvm.PointerType(recordType, 0), "")
+	j := 0
+	for i, arg := range args {
+		if arg != nil {
+			continue
+		}
+		ptr := c.builder.CreateStructGEP(record, j+2, "")
+		value := c.NewLLVMValue(c.builder.CreateLoad(ptr, ""), values[j].Type())
+		obj := ast.NewObj(ast.Var, "_")
+		obj.Type = value.Type()
+		obj.Data = value
+		args[i] = &ast.Ident{Name: "_", Obj: obj}
+		j++
+	}
+	c.VisitCallExpr(&ast.CallExpr{Fun: call.Fun, Args: args})
+	c.builder.CreateRetVoid()
+	c.functions = c.functions[0 : len(c.functions)-1]
+}
+
+// vim: set ft=go :
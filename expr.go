@@ -85,6 +85,9 @@ func (c *compiler) VisitBinaryExpr(expr *ast.BinaryExpr) Value {
 
 func (c *compiler) VisitUnaryExpr(expr *ast.UnaryExpr) Value {
 	value := c.VisitExpr(expr.X)
+	if expr.Op == token.ARROW {
+		return c.chanRecv(value.(*LLVMValue), false)
+	}
 	return value.UnaryOp(expr.Op)
 }
 
@@ -96,13 +99,33 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 			return c.VisitPrintln(expr)
 		case "len":
 			return c.VisitLen(expr)
+		case "cap":
+			return c.VisitCap(expr)
+		case "append":
+			return c.VisitAppend(expr)
+		case "close":
+			return c.VisitClose(expr)
+		case "complex":
+			return c.VisitComplex(expr)
+		case "copy":
+			return c.VisitCopy(expr)
+		case "real":
+			return c.VisitReal(expr)
+		case "imag":
+			return c.VisitImag(expr)
+		case "delete":
+			return c.VisitDelete(expr)
+		case "panic":
+			return c.VisitPanic(expr)
+		case "recover":
+			return c.VisitRecover(expr)
 		case "new":
 			return c.VisitNew(expr)
 		case "make":
 			return c.VisitMake(expr)
 		}
 	}
-	lhs := c.VisitExpr(expr.Fun)
+	lhs := c.visitCallee(expr.Fun)
 
 	// Is it a type conversion?
 	if len(expr.Args) == 1 {
@@ -115,6 +138,39 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 
 	// Not a type conversion, so must be a function call.
 	fn := lhs.(*LLVMValue)
+	fn_type := fn.Type().(*types.Func)
+	args := c.evalCallArgs(fn, expr.Args)
+
+	var result_type types.Type
+	switch len(fn_type.Results) {
+	case 0: // no-op
+	case 1:
+		result_type = fn_type.Results[0].Type.(types.Type)
+	default:
+		fields := make([]*ast.Object, len(fn_type.Results))
+		for i, result := range fn_type.Results {
+			fields[i] = result
+		}
+		result_type = &types.Struct{Fields: fields}
+	}
+
+	return c.NewLLVMValue(c.callFunc(fn, args), result_type)
+}
+
+// visitCallee evaluates the function operand of a call. Method selectors
+// yield the method with its receiver attached, rather than a bound method
+// value, so that the method may be called directly.
+func (c *compiler) visitCallee(fun ast.Expr) Value {
+	if sel, ok := unparen(fun).(*ast.SelectorExpr); ok {
+		return c.visitSelectorExpr(sel, true)
+	}
+	return c.VisitExpr(fun)
+}
+
+// evalCallArgs evaluates the arguments to a call of fn, converting them to
+// the parameter types, and returns them prefixed by fn's receiver (if any).
+// Variadic arguments are collected into a slice.
+func (c *compiler) evalCallArgs(fn *LLVMValue, exprs []ast.Expr) []llvm.Value {
 	fn_type := fn.Type().(*types.Func)
 	args := make([]llvm.Value, 0)
 	if fn_type.Recv != nil {
@@ -128,39 +184,38 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 			nparams--
 		}
 		for i := 0; i < nparams; i++ {
-			value := c.VisitExpr(expr.Args[i])
+			value := c.VisitExpr(exprs[i])
 			param_type := fn_type.Params[i].Type.(types.Type)
 			args = append(args, value.Convert(param_type).LLVMValue())
 		}
 		if fn_type.IsVariadic {
-			param_type := fn_type.Params[nparams].Type.(*types.Slice).Elt
+			slice_type := fn_type.Params[nparams].Type.(*types.Slice)
+			param_type := slice_type.Elt
 			varargs := make([]llvm.Value, 0)
-			for i := nparams; i < len(expr.Args); i++ {
-				value := c.VisitExpr(expr.Args[i])
+			for i := nparams; i < len(exprs); i++ {
+				value := c.VisitExpr(exprs[i])
 				value = value.Convert(param_type)
 				varargs = append(varargs, value.LLVMValue())
 			}
-			slice_value := c.makeSlice(varargs, param_type)
-			args = append(args, slice_value)
+			slice_value := c.makeLiteralSlice(varargs, slice_type)
+			args = append(args, slice_value.LLVMValue())
 		}
 	}
+	return args
+}
 
-	var result_type types.Type
-	switch len(fn_type.Results) {
-	case 0: // no-op
-	case 1:
-		result_type = fn_type.Results[0].Type.(types.Type)
-	default:
-		fields := make([]*ast.Object, len(fn_type.Results))
-		for i, result := range fn_type.Results {
-			fields[i] = result
+func isStringType(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.Name:
+			t = x.Underlying
+		case *types.Basic:
+			return x.Kind == types.StringKind
+		default:
+			return false
 		}
-		result_type = &types.Struct{Fields: fields}
 	}
-
-	return c.NewLLVMValue(
-		c.builder.CreateCall(fn.LLVMValue(), args, ""),
-		result_type)
+	panic("unreachable")
 }
 
 func isIntType(t types.Type) bool {
@@ -185,17 +240,73 @@ func isIntType(t types.Type) bool {
 	panic("unreachable")
 }
 
+// isFloatType reports whether t is a floating point type.
+func isFloatType(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.Name:
+			t = x.Underlying
+		case *types.Basic:
+			return x.Kind == types.Float32Kind || x.Kind == types.Float64Kind
+		default:
+			return false
+		}
+	}
+	panic("unreachable")
+}
+
+// isComplexType reports whether t is a complex type.
+func isComplexType(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.Name:
+			t = x.Underlying
+		case *types.Basic:
+			return x.Kind == types.Complex64Kind || x.Kind == types.Complex128Kind
+		default:
+			return false
+		}
+	}
+	panic("unreachable")
+}
+
+// isUnsignedType reports whether t is an unsigned integer type.
+func isUnsignedType(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.Name:
+			t = x.Underlying
+		case *types.Basic:
+			switch x.Kind {
+			case types.UintKind, types.Uint8Kind, types.Uint16Kind,
+				types.Uint32Kind, types.Uint64Kind, types.UintptrKind:
+				return true
+			}
+			return false
+		default:
+			return false
+		}
+	}
+	panic("unreachable")
+}
+
 func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
-	value := c.VisitExpr(expr.X).(*LLVMValue)
-	index := c.VisitExpr(expr.Index)
+	return c.visitIndexExpr(expr, false)
+}
 
-	typ := value.Type()
-	if typ == types.String {
-		// TODO
-		panic("unimplemented")
+// visitIndexExpr compiles an index expression. If assign is true and the
+// operand is a map, then the key is inserted into the map, and the result
+// is the addressable element for the key.
+func (c *compiler) visitIndexExpr(expr *ast.IndexExpr, assign bool) *LLVMValue {
+	x := c.VisitExpr(expr.X)
+	index := c.VisitExpr(expr.Index)
+	if isStringType(x.Type()) {
+		return c.stringIndex(x, index)
 	}
 
-	switch value.Type().(type) {
+	value := x.(*LLVMValue)
+
+	switch types.Underlying(value.Type()).(type) {
 	case *types.Array, *types.Slice:
 		if !isIntType(index.Type()) {
 			panic("Array index expression must evaluate to an integer")
@@ -204,15 +315,14 @@ func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
 
 		var ptr llvm.Value
 		var result_type types.Type
-		switch typ := value.Type().(type) {
+		switch typ := types.Underlying(value.Type()).(type) {
 		case *types.Array:
 			result_type = typ.Elt
 			ptr = value.pointer.LLVMValue()
 			gep_indices = append(gep_indices, llvm.ConstNull(llvm.Int32Type()))
 		case *types.Slice:
 			result_type = typ.Elt
-			ptr = c.builder.CreateStructGEP(value.pointer.LLVMValue(), 0, "")
-			ptr = c.builder.CreateLoad(ptr, "")
+			ptr = c.builder.CreateExtractValue(value.LLVMValue(), 0, "")
 		default:
 			panic("unimplemented")
 		}
@@ -223,8 +333,10 @@ func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
 		return result.makePointee()
 
 	case *types.Map:
-		// FIXME we need to differentiate between insertion and lookup.
-		return c.mapInsert(value, index)
+		if assign {
+			return c.mapInsert(value, index)
+		}
+		return c.mapLookup(value, index, false)
 	}
 	panic("unreachable")
 }
@@ -235,6 +347,14 @@ type selectorCandidate struct {
 }
 
 func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
+	return c.visitSelectorExpr(expr, false)
+}
+
+// visitSelectorExpr compiles a selector expression. If call is true and
+// the selector denotes a method, then the result is the method with its
+// receiver attached, which must be called immediately; otherwise the
+// method is bound to its receiver to produce a func value.
+func (c *compiler) visitSelectorExpr(expr *ast.SelectorExpr, call bool) Value {
 	lhs := c.VisitExpr(expr.X)
 	if lhs == nil {
 		// The only time we should get a nil result is if the object is
@@ -246,26 +366,37 @@ func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
 		return c.Resolve(obj)
 	}
 
+	if typ, ok := lhs.(TypeValue); ok {
+		return c.methodExpr(typ.Type(), expr.Sel)
+	}
+
+	result := c.selector(lhs, expr.Sel)
+	if fn, ok := result.Type().(*types.Func); ok && fn.Recv != nil && !call {
+		return c.bindMethod(result.(*LLVMValue))
+	}
+	return result
+}
+
+// selector selects the field or method sel of lhs. Methods are returned
+// with their receiver attached.
+func (c *compiler) selector(lhs Value, sel *ast.Ident) Value {
 	// TODO(?) record path to field/method during typechecking, so we don't
 	// have to search again here.
 
-	name := expr.Sel.Name
+	name := sel.Name
 	if iface, ok := types.Underlying(lhs.Type()).(*types.Interface); ok {
 		// validity checked during typechecking.
 		i := sort.Search(len(iface.Methods), func(i int) bool {
 			return iface.Methods[i].Name >= name
 		})
 		struct_value := lhs.LLVMValue()
-		receiver_value := c.builder.CreateStructGEP(struct_value, 0, "")
-		fn_value := c.builder.CreateStructGEP(struct_value, i+2, "")
-		method_type := c.ObjGetType(iface.Methods[i]).(*types.Func)
-		method := c.NewLLVMValue(
-			c.builder.CreateBitCast(
-				c.builder.CreateLoad(fn_value, ""),
-				c.types.ToLLVM(method_type), ""), method_type)
+		receiver_value := c.builder.CreateExtractValue(struct_value, 0, "")
+		itab := c.builder.CreateExtractValue(struct_value, 1, "")
+		method_type := interfaceMethodType(iface.Methods[i])
+		fn_value := c.itabMethod(itab, i, method_type)
+		method := c.NewLLVMValue(fn_value, method_type)
 		method.receiver = c.NewLLVMValue(
-			c.builder.CreateLoad(receiver_value, ""),
-			method_type.Recv.Type.(types.Type))
+			receiver_value, method_type.Recv.Type.(types.Type))
 		return method
 	}
 
@@ -323,12 +454,19 @@ func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
 	}
 
 	// Method?
-	if expr.Sel.Obj.Kind == ast.Fun {
-		method := c.Resolve(expr.Sel.Obj).(*LLVMValue)
-		methodType := expr.Sel.Obj.Type.(*types.Func)
+	if sel.Obj.Kind == ast.Fun {
+		method := c.Resolve(sel.Obj).(*LLVMValue)
+		methodType := sel.Obj.Type.(*types.Func)
 		receiverType := methodType.Recv.Type.(types.Type)
 		if len(indices) > 0 {
-			receiverValue := c.builder.CreateGEP(lhs.LLVMValue(), indices, "")
+			ptr := lhs.LLVMValue()
+			if _, ok := types.Underlying(lhs.Type()).(*types.Pointer); !ok {
+				// Promoted method of an addressable struct value.
+				ptr = lhs.(*LLVMValue).pointer.LLVMValue()
+				zero := llvm.ConstNull(llvm.Int32Type())
+				indices = append([]llvm.Value{zero}, indices...)
+			}
+			receiverValue := c.builder.CreateGEP(ptr, indices, "")
 			if types.Identical(result.Type, receiverType) {
 				receiverValue = c.builder.CreateLoad(receiverValue, "")
 			}
@@ -359,7 +497,7 @@ func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
 			}
 		}
 		fieldValue := c.builder.CreateGEP(ptr, indices, "")
-		fieldType := &types.Pointer{Base: expr.Sel.Obj.Type.(types.Type)}
+		fieldType := &types.Pointer{Base: sel.Obj.Type.(types.Type)}
 		return c.NewLLVMValue(fieldValue, fieldType).makePointee()
 	}
 	panic("unreachable")
@@ -379,16 +517,15 @@ func (c *compiler) VisitStarExpr(expr *ast.StarExpr) Value {
 }
 
 func (c *compiler) VisitTypeAssertExpr(expr *ast.TypeAssertExpr) Value {
-	if expr.Type == nil {
-		// .(type) switch
-		// XXX this will probably be handled in the switch statement.
-		panic("TODO")
-	} else {
-		lhs := c.VisitExpr(expr.X)
-		typ := c.GetType(expr.Type)
-		return lhs.Convert(typ)
+	// .(type) expressions are handled by VisitTypeSwitchStmt.
+	lhs := c.VisitExpr(expr.X)
+	typ := c.GetType(expr.Type)
+	if _, isiface := types.Underlying(typ).(*types.Interface); isiface {
+		// Assertions to interface types always check the dynamic type,
+		// even if the conversion could be made statically.
+		return lhs.(*LLVMValue).assertI2I(typ)
 	}
-	return nil
+	return lhs.Convert(typ)
 }
 
 func (c *compiler) VisitExpr(expr ast.Expr) Value {
@@ -407,6 +544,8 @@ func (c *compiler) VisitExpr(expr ast.Expr) Value {
 		return c.VisitCallExpr(x)
 	case *ast.IndexExpr:
 		return c.VisitIndexExpr(x)
+	case *ast.SliceExpr:
+		return c.VisitSliceExpr(x)
 	case *ast.SelectorExpr:
 		return c.VisitSelectorExpr(x)
 	case *ast.StarExpr:
@@ -415,6 +554,8 @@ func (c *compiler) VisitExpr(expr ast.Expr) Value {
 		return c.VisitExpr(x.X)
 	case *ast.TypeAssertExpr:
 		return c.VisitTypeAssertExpr(x)
+	case *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType:
+		return TypeValue{c.GetType(x)}
 	case *ast.Ident:
 		if x.Obj == nil {
 			x.Obj = c.LookupObj(x.Name)
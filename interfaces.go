@@ -25,6 +25,7 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
+	"go/ast"
 	"sort"
 )
 
@@ -61,16 +62,23 @@ func (v *LLVMValue) convertV2I(iface *types.Interface) Value {
 	if isptr {
 		ptr = v.LLVMValue()
 	} else {
-		// If the value fits exactly in a pointer, then we can just
-		// bitcast it. Otherwise we need to malloc, and create a shim
-		// function to load the receiver.
+		// If the value fits in a pointer, then we store its bits in the
+		// pointer itself. Otherwise we need to malloc. In either case,
+		// methods are called via shims which convert the pointer back to
+		// the receiver value; see InterfaceMethod.
 		lv := v.LLVMValue()
 		c := v.compiler
 		ptrsize := c.target.PointerSize()
 		if c.target.TypeStoreSize(lv.Type()) <= uint64(ptrsize) {
 			bits := c.target.TypeSizeInBits(lv.Type())
 			if bits > 0 {
-				lv = builder.CreateBitCast(lv, llvm.IntType(int(bits)), "")
+				// Aggregates cannot be bitcast to an integer, so
+				// reinterpret the value through memory.
+				inttype := llvm.IntType(int(bits))
+				mem := c.entryAlloca(lv.Type(), "")
+				builder.CreateStore(lv, mem)
+				mem = builder.CreateBitCast(mem, llvm.PointerType(inttype, 0), "")
+				lv = builder.CreateLoad(mem, "")
 				ptr = builder.CreateIntToPtr(lv, element_types[0], "")
 			} else {
 				ptr = llvm.ConstNull(element_types[0])
@@ -78,77 +86,115 @@ func (v *LLVMValue) convertV2I(iface *types.Interface) Value {
 		} else {
 			ptr = builder.CreateMalloc(v.compiler.types.ToLLVM(srctyp), "")
 			builder.CreateStore(lv, ptr)
-			// TODO signal that shim functions are required. Probably later
-			// we'll have the CallExpr handler pick out the type, and check
-			// if the receiver is a pointer or a value type, and load as
-			// necessary.
 		}
 	}
 	ptr = builder.CreateBitCast(ptr, element_types[0], "")
 	iface_struct = builder.CreateInsertValue(iface_struct, ptr, 0, "")
 
-	var runtimeType llvm.Value
-	if srcname != nil {
-		runtimeType = v.compiler.types.ToRuntime(srcname)
+	// The second word is the dynamic type for empty interfaces, and the
+	// itab otherwise.
+	var typ llvm.Value
+	if len(iface.Methods) == 0 {
+		typ = v.compiler.types.ToRuntime(v.Type())
 	} else {
-		runtimeType = v.compiler.types.ToRuntime(srctyp)
+		typ = v.compiler.makeItab(iface, v.Type(), srcname, isptr)
+	}
+	typ = builder.CreateBitCast(typ, element_types[1], "")
+	iface_struct = builder.CreateInsertValue(iface_struct, typ, 1, "")
+	return v.compiler.NewLLVMValue(iface_struct, iface)
+}
+
+// itabKey identifies the itab for an interface type and dynamic type, by
+// their runtime types.
+type itabKey struct {
+	iface, typ llvm.Value
+}
+
+// makeItab returns the itab for the interface type iface and the dynamic
+// type typ, which is either the named type n or a pointer to it (if ptr is
+// true). An itab consists of the dynamic type, the interface type, a link
+// used by the runtime's itab cache, and the functions implementing the
+// interface's methods. Itabs are created once for each pair of types.
+func (c *compiler) makeItab(iface *types.Interface, typ types.Type, n *types.Name, ptr bool) llvm.Value {
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	ifaceType := c.types.ToRuntime(iface)
+	runtimeType := c.types.ToRuntime(typ)
+	key := itabKey{ifaceType, runtimeType}
+	if itab, ok := c.itabs[key]; ok {
+		return itab
 	}
-	runtimeType = builder.CreateBitCast(runtimeType, element_types[1], "")
-	iface_struct = builder.CreateInsertValue(iface_struct, runtimeType, 1, "")
 
 	// TODO assert either source is a named type (or pointer to), or the
 	// interface has an empty methodset.
-
-	if srcname != nil {
-		// TODO check whether the functions in the struct take
-		// value or pointer receivers.
-
-		// Look up the method by name.
-		methods := srcname.Methods
-		for i, m := range iface.Methods {
-			// TODO make this loop linear by iterating through the
-			// interface methods and type methods together.
-			mi := sort.Search(len(methods), func(i int) bool {
-				return methods[i].Name >= m.Name
-			})
-			if mi >= len(methods) || methods[mi].Name != m.Name {
-				panic("Failed to locate method: " + m.Name)
-			}
-			method_obj := methods[mi]
-			method := v.compiler.Resolve(method_obj).(*LLVMValue)
-			llvm_value := method.LLVMValue()
-			llvm_value = builder.CreateBitCast(
-				llvm_value, element_types[i+2], "")
-			iface_struct = builder.CreateInsertValue(
-				iface_struct, llvm_value, i+2, "")
+	elements := []llvm.Value{
+		llvm.ConstBitCast(runtimeType, i8ptr),
+		llvm.ConstBitCast(ifaceType, i8ptr),
+		llvm.ConstNull(i8ptr),
+	}
+	var methods types.ObjList
+	if n != nil {
+		methods = n.Methods
+	}
+	for _, m := range iface.Methods {
+		// TODO make this loop linear by iterating through the
+		// interface methods and type methods together.
+		mi := sort.Search(len(methods), func(i int) bool {
+			return methods[i].Name >= m.Name
+		})
+		if mi >= len(methods) || methods[mi].Name != m.Name {
+			panic("Failed to locate method: " + m.Name)
 		}
+		fn := c.InterfaceMethod(methods[mi], ptr)
+		elements = append(elements, llvm.ConstBitCast(fn, i8ptr))
 	}
 
-	return v.compiler.NewLLVMValue(iface_struct, iface)
+	init := llvm.ConstArray(i8ptr, elements)
+	itab := llvm.AddGlobal(c.module.Module, init.Type(), "")
+	itab.SetInitializer(init)
+	itab.SetGlobalConstant(true)
+	itab.SetLinkage(llvm.InternalLinkage)
+	if c.itabs == nil {
+		c.itabs = make(map[itabKey]llvm.Value)
+	}
+	c.itabs[key] = itab
+	return itab
 }
 
-// convertI2I converts an interface to another interface.
-func (v *LLVMValue) convertI2I(iface *types.Interface) Value {
-	builder := v.compiler.builder
-	src_typ := v.Type()
-	vptr := v.pointer.LLVMValue()
-	src_typ = src_typ.(*types.Name).Underlying
+// itabMethod loads the function implementing the i'th method of an
+// interface from an itab, as a func value of the specified type.
+func (c *compiler) itabMethod(itab llvm.Value, i int, fntype *types.Func) llvm.Value {
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	fn_pair_type := c.types.ToLLVM(fntype)
+	fns := c.builder.CreateBitCast(itab, llvm.PointerType(i8ptr, 0), "")
+	index := llvm.ConstInt(llvm.Int32Type(), uint64(3+i), false)
+	fn := c.builder.CreateLoad(c.builder.CreateGEP(fns, []llvm.Value{index}, ""), "")
+	fn = c.builder.CreateBitCast(fn, fn_pair_type.StructElementTypes()[0], "")
+	return c.builder.CreateInsertValue(llvm.ConstNull(fn_pair_type), fn, 0, "")
+}
 
-	iface_struct_type := v.compiler.types.ToLLVM(iface)
-	element_types := iface_struct_type.StructElementTypes()
-	iface_elements := make([]llvm.Value, len(element_types))
-	for i, _ := range iface_elements {
-		iface_elements[i] = llvm.ConstNull(element_types[i])
+// interfaceMethodType returns the type of the function implementing an
+// interface method, whose receiver is the value pointer of the interface.
+func interfaceMethodType(m *ast.Object) *types.Func {
+	fntype := m.Type.(*types.Func)
+	recv := ast.NewObj(ast.Var, "")
+	recv.Type = &types.Pointer{Base: types.Int8}
+	return &types.Func{
+		Recv:       recv,
+		Params:     fntype.Params,
+		Results:    fntype.Results,
+		IsVariadic: fntype.IsVariadic,
 	}
-	iface_struct := llvm.ConstStruct(iface_elements, false)
-	receiver := builder.CreateLoad(builder.CreateStructGEP(vptr, 0, ""), "")
-	iface_struct = builder.CreateInsertValue(iface_struct, receiver, 0, "")
-
-	// TODO check whether the functions in the struct take
-	// value or pointer receivers.
+}
 
-	// TODO handle dynamic interface conversion (non-subset).
-	methods := src_typ.(*types.Interface).Methods
+// convertI2I converts an interface to another interface, whose methods
+// must be a subset of the source interface's methods. If they are not, then
+// the conversion is checked dynamically, as for a type assertion.
+func (v *LLVMValue) convertI2I(iface *types.Interface) Value {
+	c := v.compiler
+	builder := c.builder
+	src_typ := types.Underlying(v.Type()).(*types.Interface)
+	methods := src_typ.Methods
+	prefix := true
 	for i, m := range iface.Methods {
 		// TODO make this loop linear by iterating through the
 		// interface methods and type methods together.
@@ -156,55 +202,299 @@ func (v *LLVMValue) convertI2I(iface *types.Interface) Value {
 			return methods[i].Name >= m.Name
 		})
 		if mi >= len(methods) || methods[mi].Name != m.Name {
-			panic("Failed to locate method: " + m.Name)
+			return v.assertI2I(iface)
 		}
-		method := builder.CreateStructGEP(vptr, mi+2, "")
-		iface_struct = builder.CreateInsertValue(
-			iface_struct, builder.CreateLoad(method, ""), i+2, "")
+		prefix = prefix && mi == i
 	}
-	return v.compiler.NewLLVMValue(iface_struct, iface)
+
+	// The dynamic type is the same, so we need only find the itab, if the
+	// target interface is non-empty. If the target's methods are the first
+	// methods of the source, then the source's itab holds the functions
+	// in the right order, and is shared. If the source is nil, then so is
+	// the itab, and the result.
+	iface_struct := llvm.ConstNull(c.types.ToLLVM(iface))
+	receiver := builder.CreateExtractValue(v.LLVMValue(), 0, "")
+	iface_struct = builder.CreateInsertValue(iface_struct, receiver, 0, "")
+	var typ llvm.Value
+	switch {
+	case len(iface.Methods) == 0:
+		typ = v.interfaceType()
+	case prefix:
+		typ = builder.CreateExtractValue(v.LLVMValue(), 1, "")
+	default:
+		typ = c.getitab(v.interfaceType(), iface)
+	}
+	iface_struct = builder.CreateInsertValue(iface_struct, typ, 1, "")
+	return c.NewLLVMValue(iface_struct, iface)
 }
 
-// convertI2V converts an interface to a value.
-func (v *LLVMValue) convertI2V(typ types.Type) Value {
-	typptrType := llvm.PointerType(llvm.Int8Type(), 0)
-	runtimeType := v.compiler.types.ToRuntime(typ)
-	runtimeType = llvm.ConstBitCast(runtimeType, typptrType)
-	vptr := v.pointer.LLVMValue()
+// getitab calls runtime.getitab, which returns the itab for the specified
+// interface type and dynamic type, or nil if the dynamic type is nil or
+// does not implement the interface.
+func (c *compiler) getitab(typ llvm.Value, iface *types.Interface) llvm.Value {
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	getitab := c.namedFunction("runtime.getitab", funcType)
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(typ, ptrType, ""),
+		llvm.ConstPtrToInt(c.types.ToRuntime(iface), ptrType)}
+	itab := c.builder.CreateCall(getitab, args, "")
+	return c.builder.CreateIntToPtr(itab, typ.Type(), "")
+}
 
+// assertI2I converts an interface to another interface, by looking up the
+// methods in the method table of the interface value's dynamic type. If the
+// interface value is nil, or its dynamic type does not implement the target
+// interface, then assertI2I panics.
+func (v *LLVMValue) assertI2I(typ types.Type) Value {
 	builder := v.compiler.builder
-	ifaceType := builder.CreateLoad(builder.CreateStructGEP(vptr, 1, ""), "")
-	diff := builder.CreatePtrDiff(runtimeType, ifaceType, "")
-	zero := llvm.ConstNull(diff.Type())
-	predicate := builder.CreateICmp(llvm.IntEQ, diff, zero, "")
-	llvmtype := v.compiler.types.ToLLVM(typ)
-	result := builder.CreateAlloca(llvmtype, "")
-
-	// If result is zero, then we've got a match.
-	end := llvm.InsertBasicBlock(builder.GetInsertBlock(), "end")
-	end.MoveAfter(builder.GetInsertBlock())
-	nonmatch := llvm.InsertBasicBlock(end, "nonmatch")
-	match := llvm.InsertBasicBlock(nonmatch, "match")
-	builder.CreateCondBr(predicate, match, nonmatch)
+	ok, value := v.convertI2IDynamic(types.Underlying(typ).(*types.Interface))
+
+	currBlock := builder.GetInsertBlock()
+	match := llvm.AddBasicBlock(currBlock.Parent(), "match")
+	match.MoveAfter(currBlock)
+	nonmatch := llvm.InsertBasicBlock(match, "nonmatch")
+	builder.CreateCondBr(ok, match, nonmatch)
+
+	builder.SetInsertPointAtEnd(nonmatch)
+	v.assertFailed(typ)
 
 	builder.SetInsertPointAtEnd(match)
-	value := builder.CreateLoad(builder.CreateStructGEP(vptr, 0, ""), "")
-	value = builder.CreateBitCast(value, result.Type(), "")
-	value = builder.CreateLoad(value, "")
-	builder.CreateStore(value, result)
-	builder.CreateBr(end)
+	return v.compiler.NewLLVMValue(value.LLVMValue(), typ)
+}
+
+// convertI2IDynamic converts an interface to another interface, by looking
+// up the methods in the method table of the interface value's dynamic type.
+// The first result is an i1 indicating whether the dynamic type implements
+// the interface; if it does not, the resulting interface value is invalid.
+func (v *LLVMValue) convertI2IDynamic(iface *types.Interface) (llvm.Value, Value) {
+	c := v.compiler
+	builder := c.builder
+	typ := v.interfaceType()
+	if len(iface.Methods) > 0 {
+		typ = c.getitab(typ, iface)
+	}
+	ok := builder.CreateIsNotNull(typ, "")
+
+	iface_struct := llvm.ConstNull(c.types.ToLLVM(iface))
+	receiver := builder.CreateExtractValue(v.LLVMValue(), 0, "")
+	iface_struct = builder.CreateInsertValue(iface_struct, receiver, 0, "")
+	iface_struct = builder.CreateInsertValue(iface_struct, typ, 1, "")
+	return ok, c.NewLLVMValue(iface_struct, iface)
+}
+
+// convertI2V converts an interface to a value, panicking if the dynamic
+// type of the interface is not the specified type.
+func (v *LLVMValue) convertI2V(typ types.Type) Value {
+	builder := v.compiler.builder
+	predicate := v.interfaceTypeEquals(typ)
+
+	// If the dynamic type is typ, then we've got a match.
+	currBlock := builder.GetInsertBlock()
+	match := llvm.AddBasicBlock(currBlock.Parent(), "match")
+	match.MoveAfter(currBlock)
+	nonmatch := llvm.InsertBasicBlock(match, "nonmatch")
+	builder.CreateCondBr(predicate, match, nonmatch)
 
-	// TODO should return {value, ok}
 	builder.SetInsertPointAtEnd(nonmatch)
+	v.assertFailed(typ)
+
+	builder.SetInsertPointAtEnd(match)
+	return v.loadI2V(typ)
+}
+
+// convertI2VCommaOk converts an interface to a value, yielding a {value,
+// ok} pair. If the dynamic type of the interface is not the specified type,
+// then the value is the type's zero value, and ok is false.
+func (v *LLVMValue) convertI2VCommaOk(typ types.Type) *LLVMValue {
+	c := v.compiler
+	builder := c.builder
+	predicate := v.interfaceTypeEquals(typ)
+	llvmtype := c.types.ToLLVM(typ)
+	result := c.entryAlloca(llvmtype, "")
 	builder.CreateStore(llvm.ConstNull(llvmtype), result)
+
+	currBlock := builder.GetInsertBlock()
+	end := llvm.AddBasicBlock(currBlock.Parent(), "end")
+	end.MoveAfter(currBlock)
+	match := llvm.InsertBasicBlock(end, "match")
+	builder.CreateCondBr(predicate, match, end)
+
+	builder.SetInsertPointAtEnd(match)
+	builder.CreateStore(v.loadI2V(typ).LLVMValue(), result)
+	builder.CreateBr(end)
+
+	builder.SetInsertPointAtEnd(end)
+	value := c.NewLLVMValue(builder.CreateLoad(result, ""), typ)
+	return c.commaOkValue(value, predicate)
+}
+
+// convertI2ICommaOk converts an interface to another interface, yielding a
+// {value, ok} pair. If the dynamic type of the interface does not implement
+// the target interface, then the value is nil, and ok is false.
+func (v *LLVMValue) convertI2ICommaOk(iface types.Type) *LLVMValue {
+	c := v.compiler
+	ok, value := v.convertI2IDynamic(types.Underlying(iface).(*types.Interface))
+	zero := llvm.ConstNull(value.LLVMValue().Type())
+	result := c.builder.CreateSelect(ok, value.LLVMValue(), zero, "")
+	return c.commaOkValue(c.NewLLVMValue(result, iface), ok)
+}
+
+// assertFailed calls runtime.assertfailed, which panics with the error for
+// a failed type assertion of the interface value to the specified type.
+func (v *LLVMValue) assertFailed(typ types.Type) {
+	c := v.compiler
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.VoidType(), paramTypes, false)
+	assertfailed := c.namedFunction("runtime.assertfailed", funcType)
+	args := []llvm.Value{
+		llvm.ConstPtrToInt(c.types.ToRuntime(v.Type()), ptrType),
+		c.builder.CreatePtrToInt(v.interfaceType(), ptrType, ""),
+		llvm.ConstPtrToInt(c.types.ToRuntime(typ), ptrType)}
+	c.createCall(assertfailed, args)
+	c.builder.CreateUnreachable()
+}
+
+// loadI2V extracts the dynamic value of an interface, which must be of the
+// specified type. As in convertV2I, values no larger than a pointer are
+// stored in the interface's value pointer itself.
+func (v *LLVMValue) loadI2V(typ types.Type) Value {
+	c := v.compiler
+	builder := c.builder
+	llvmtype := c.types.ToLLVM(typ)
+	ptr := builder.CreateExtractValue(v.LLVMValue(), 0, "")
+	if _, isptr := types.Underlying(typ).(*types.Pointer); isptr {
+		value := builder.CreateBitCast(ptr, llvmtype, "")
+		return c.NewLLVMValue(value, typ)
+	}
+
+	return c.NewLLVMValue(c.unboxValue(ptr, llvmtype), typ)
+}
+
+// unboxValue converts the value pointer of an interface to the dynamic
+// value, of the specified non-pointer type. As in convertV2I, values no
+// larger than a pointer are stored in the pointer itself, and larger values
+// are pointed to.
+func (c *compiler) unboxValue(ptr llvm.Value, llvmtype llvm.Type) llvm.Value {
+	builder := c.builder
+	if c.target.TypeStoreSize(llvmtype) <= uint64(c.target.PointerSize()) {
+		bits := c.target.TypeSizeInBits(llvmtype)
+		if bits == 0 {
+			return llvm.ConstNull(llvmtype)
+		}
+		inttype := llvm.IntType(int(bits))
+		mem := c.entryAlloca(inttype, "")
+		builder.CreateStore(builder.CreatePtrToInt(ptr, inttype, ""), mem)
+		mem = builder.CreateBitCast(mem, llvm.PointerType(llvmtype, 0), "")
+		return builder.CreateLoad(mem, "")
+	}
+	ptr = builder.CreateBitCast(ptr, llvm.PointerType(llvmtype, 0), "")
+	return builder.CreateLoad(ptr, "")
+}
+
+// interfaceMethodKey identifies a method called through an interface,
+// and whether the interface holds a pointer to the receiver's base type.
+type interfaceMethodKey struct {
+	method *ast.Object
+	ptr    bool
+}
+
+// InterfaceMethod returns the function stored in the method table of an
+// interface holding a value of the method's receiver type (or a pointer to
+// it, if ptr is true). Interface methods take the interface's value pointer
+// as their receiver, so methods with pointer receivers are called directly,
+// while methods with value receivers are called via a shim which loads the
+// receiver value and calls the method.
+func (c *compiler) InterfaceMethod(m *ast.Object, ptr bool) llvm.Value {
+	fn := c.Resolve(m).LLVMValue()
+	fn = llvm.ConstExtractValue(fn, []uint32{0})
+	recvType := m.Type.(*types.Func).Recv.Type.(types.Type)
+	if _, isptr := recvType.(*types.Pointer); isptr {
+		if !ptr {
+			panic("method " + m.Name + " has pointer receiver")
+		}
+		return fn
+	}
+
+	key := interfaceMethodKey{m, ptr}
+	if shim, ok := c.shims[key]; ok {
+		return shim
+	}
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	fnType := fn.Type().ElementType()
+	paramTypes := append([]llvm.Type{i8ptr}, fnType.ParamTypes()[1:]...)
+	shimType := llvm.FunctionType(fnType.ReturnType(), paramTypes, false)
+	shim := llvm.AddFunction(c.module.Module, "", shimType)
+	if c.shims == nil {
+		c.shims = make(map[interfaceMethodKey]llvm.Value)
+	}
+	c.shims[key] = shim
+
+	// When done, return to where we were.
+	if block := c.builder.GetInsertBlock(); !block.IsNil() {
+		defer c.builder.SetInsertPointAtEnd(block)
+	}
+	entry := llvm.AddBasicBlock(shim, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	var receiver llvm.Value
+	recvLLVMType := c.types.ToLLVM(recvType)
+	if ptr {
+		ptr := c.builder.CreateBitCast(
+			shim.Param(0), llvm.PointerType(recvLLVMType, 0), "")
+		receiver = c.builder.CreateLoad(ptr, "")
+	} else {
+		receiver = c.unboxValue(shim.Param(0), recvLLVMType)
+	}
+	args := []llvm.Value{receiver}
+	for i := 1; i < len(paramTypes); i++ {
+		args = append(args, shim.Param(i))
+	}
+	result := c.builder.CreateCall(fn, args, "")
+	if fnType.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		c.builder.CreateRetVoid()
+	} else {
+		c.builder.CreateRet(result)
+	}
+	return shim
+}
+
+// interfaceType returns the runtime type of an interface value's dynamic
+// type, which is null if the interface value is nil. The dynamic type of a
+// non-empty interface value is stored in its itab.
+func (v *LLVMValue) interfaceType() llvm.Value {
+	builder := v.compiler.builder
+	typ := builder.CreateExtractValue(v.LLVMValue(), 1, "")
+	if len(types.Underlying(v.Type()).(*types.Interface).Methods) == 0 {
+		return typ
+	}
+
+	currBlock := builder.GetInsertBlock()
+	end := llvm.AddBasicBlock(currBlock.Parent(), "")
+	end.MoveAfter(currBlock)
+	nonnil := llvm.InsertBasicBlock(end, "")
+	builder.CreateCondBr(builder.CreateIsNull(typ, ""), end, nonnil)
+
+	builder.SetInsertPointAtEnd(nonnil)
+	itab := builder.CreateBitCast(typ, llvm.PointerType(typ.Type(), 0), "")
+	itabType := builder.CreateLoad(itab, "")
 	builder.CreateBr(end)
 
-	//builder.SetInsertPointAtEnd(end)
-	result = builder.CreateLoad(result, "")
-	return v.compiler.NewLLVMValue(result, typ)
+	builder.SetInsertPointAtEnd(end)
+	result := builder.CreatePHI(typ.Type(), "")
+	result.AddIncoming([]llvm.Value{typ, itabType},
+		[]llvm.BasicBlock{currBlock, nonnil})
+	return result
 }
 
-// 
-//func (v *LLVMValue) interfaceTypesEqual(
+// interfaceTypeEquals returns an i1 indicating whether the dynamic type of
+// an interface value is the specified type.
+func (v *LLVMValue) interfaceTypeEquals(typ types.Type) llvm.Value {
+	ifaceType := v.interfaceType()
+	runtimeType := v.compiler.types.ToRuntime(typ)
+	runtimeType = llvm.ConstBitCast(runtimeType, ifaceType.Type())
+	return v.compiler.builder.CreateICmp(llvm.IntEQ, ifaceType, runtimeType, "")
+}
 
 // vim: set ft=go :
@@ -35,7 +35,61 @@ func (c *compiler) defineRuntimeIntrinsics() {
 
 	fn = c.module.NamedFunction("runtime.memcpy")
 	if !fn.IsNil() {
-		c.defineMemcpyFunction(fn)
+		c.defineMemcpyFunction(fn, "memcpy")
+	}
+
+	fn = c.module.NamedFunction("runtime.memmove")
+	if !fn.IsNil() {
+		c.defineMemcpyFunction(fn, "memmove")
+	}
+
+	fn = c.module.NamedFunction("runtime.memset")
+	if !fn.IsNil() {
+		c.defineMemsetFunction(fn)
+	}
+
+	// Channel synchronisation: a single mutex and condition variable,
+	// shared by all channels.
+	fn = c.module.NamedFunction("runtime.chanlock")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_mutex_lock", "runtime.chanmutex")
+	}
+	fn = c.module.NamedFunction("runtime.chanunlock")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_mutex_unlock", "runtime.chanmutex")
+	}
+	fn = c.module.NamedFunction("runtime.chanwait")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_cond_wait",
+			"runtime.chancond", "runtime.chanmutex")
+	}
+	fn = c.module.NamedFunction("runtime.chansignal")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_cond_broadcast", "runtime.chancond")
+	}
+
+	// The runtime's itab cache.
+	fn = c.module.NamedFunction("runtime.itablock")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_mutex_lock", "runtime.itabmutex")
+	}
+	fn = c.module.NamedFunction("runtime.itabunlock")
+	if !fn.IsNil() {
+		c.definePthreadFunction(fn, "pthread_mutex_unlock", "runtime.itabmutex")
+	}
+
+	// Panics.
+	fn = c.module.NamedFunction("runtime.getg")
+	if !fn.IsNil() {
+		c.defineThreadLocalFunction(fn, "runtime.g")
+	}
+	fn = c.module.NamedFunction("runtime.unwind")
+	if !fn.IsNil() {
+		c.defineUnwindFunction(fn)
+	}
+	fn = c.module.NamedFunction("runtime.exit")
+	if !fn.IsNil() {
+		c.defineExitFunction(fn)
 	}
 }
 
@@ -50,7 +104,9 @@ func (c *compiler) defineMallocFunction(fn llvm.Value) {
 	c.builder.CreateRet(result)
 }
 
-func (c *compiler) defineMemcpyFunction(fn llvm.Value) {
+// defineMemcpyFunction defines a runtime function which calls the LLVM
+// intrinsic "llvm.memcpy" or "llvm.memmove", as specified by name.
+func (c *compiler) defineMemcpyFunction(fn llvm.Value, name string) {
 	entry := llvm.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 	dst, src, size := fn.Param(0), fn.Param(1), fn.Param(2)
@@ -61,7 +117,7 @@ func (c *compiler) defineMemcpyFunction(fn llvm.Value) {
 
 	sizeType := size.Type()
 	sizeBits := sizeType.IntTypeWidth()
-	memcpyName := "llvm.memcpy.p0i8.p0i8.i" + strconv.Itoa(sizeBits)
+	memcpyName := "llvm." + name + ".p0i8.p0i8.i" + strconv.Itoa(sizeBits)
 	memcpy := c.module.NamedFunction(memcpyName)
 	if memcpy.IsNil() {
 		paramtypes := []llvm.Type{
@@ -79,4 +135,175 @@ func (c *compiler) defineMemcpyFunction(fn llvm.Value) {
 	c.builder.CreateRetVoid()
 }
 
+func (c *compiler) defineMemsetFunction(fn llvm.Value) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	dst, fill, size := fn.Param(0), fn.Param(1), fn.Param(2)
+
+	pint8 := llvm.PointerType(llvm.Int8Type(), 0)
+	dst = c.builder.CreateIntToPtr(dst, pint8, "")
+
+	sizeType := size.Type()
+	sizeBits := sizeType.IntTypeWidth()
+	memsetName := "llvm.memset.p0i8.i" + strconv.Itoa(sizeBits)
+	memset := c.module.NamedFunction(memsetName)
+	if memset.IsNil() {
+		paramtypes := []llvm.Type{
+			pint8, llvm.Int8Type(), size.Type(), llvm.Int32Type(), llvm.Int1Type()}
+		memsetType := llvm.FunctionType(llvm.VoidType(), paramtypes, false)
+		memset = llvm.AddFunction(c.module.Module, memsetName, memsetType)
+	}
+
+	args := []llvm.Value{
+		dst, fill, size,
+		llvm.ConstInt(llvm.Int32Type(), 1, false), // single byte alignment
+		llvm.ConstInt(llvm.Int1Type(), 0, false),  // not volatile
+	}
+	c.builder.CreateCall(memset, args, "")
+	c.builder.CreateRetVoid()
+}
+
+// definePthreadFunction defines a runtime function with no parameters,
+// which calls the named pthread function with pointers to the named
+// globals. The globals are zero-initialised, which is equivalent to static
+// initialisation with PTHREAD_MUTEX_INITIALIZER/PTHREAD_COND_INITIALIZER.
+func (c *compiler) definePthreadFunction(fn llvm.Value, name string, globals ...string) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+
+	// XXX Large enough to hold a pthread_mutex_t or pthread_cond_t on
+	// the platforms we currently target.
+	pint8 := llvm.PointerType(llvm.Int8Type(), 0)
+	storageType := llvm.ArrayType(llvm.Int8Type(), 64)
+	args := make([]llvm.Value, len(globals))
+	paramtypes := make([]llvm.Type, len(globals))
+	for i, globalName := range globals {
+		global := c.module.NamedGlobal(globalName)
+		if global.IsNil() {
+			global = llvm.AddGlobal(c.module.Module, storageType, globalName)
+			global.SetInitializer(llvm.ConstNull(storageType))
+			global.SetLinkage(llvm.InternalLinkage)
+		}
+		args[i] = c.builder.CreateBitCast(global, pint8, "")
+		paramtypes[i] = pint8
+	}
+
+	pthreadfn := c.module.NamedFunction(name)
+	if pthreadfn.IsNil() {
+		fntype := llvm.FunctionType(llvm.Int32Type(), paramtypes, false)
+		pthreadfn = llvm.AddFunction(c.module.Module, name, fntype)
+		pthreadfn.SetFunctionCallConv(llvm.CCallConv)
+	}
+	c.builder.CreateCall(pthreadfn, args, "")
+	c.builder.CreateRetVoid()
+}
+
+// defineThreadLocalFunction defines a runtime function with no parameters,
+// which returns a pointer to the named thread-local global. The global is
+// zero-initialised, and has the type pointed to by the function's result.
+func (c *compiler) defineThreadLocalFunction(fn llvm.Value, name string) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	resultType := fn.Type().ElementType().ReturnType()
+	global := c.module.NamedGlobal(name)
+	if global.IsNil() {
+		storageType := resultType.ElementType()
+		global = llvm.AddGlobal(c.module.Module, storageType, name)
+		global.SetInitializer(llvm.ConstNull(storageType))
+		global.SetLinkage(llvm.InternalLinkage)
+		global.SetThreadLocal(true)
+	}
+	c.builder.CreateRet(c.builder.CreateBitCast(global, resultType, ""))
+}
+
+// defineUnwindFunction defines runtime.unwind, which performs a forced
+// unwind of the stack (as pthread_cancel does) using a static, thread-local
+// exception object. Forced unwinding runs the cleanup landing pads of each
+// frame; the stop function calls runtime.panicexit when the end of the
+// stack is reached.
+func (c *compiler) defineUnwindFunction(fn llvm.Value) {
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	i32 := llvm.Int32Type()
+	ptrType := c.target.IntPtrType()
+
+	// struct _Unwind_Exception
+	exceptionType := llvm.StructType([]llvm.Type{
+		llvm.Int64Type(), // exception_class
+		i8ptr,            // exception_cleanup
+		ptrType,          // private_1
+		ptrType,          // private_2
+	}, false)
+	exception := llvm.AddGlobal(c.module.Module, exceptionType, "runtime.exception")
+	exceptionInit := llvm.ConstNull(exceptionType)
+	exceptionClass := llvm.ConstInt(llvm.Int64Type(), 0x4c4c474f474f0000, false) // "LLGOGO\0\0"
+	exceptionInit = llvm.ConstInsertValue(exceptionInit, exceptionClass, []uint32{0})
+	exception.SetInitializer(exceptionInit)
+	exception.SetLinkage(llvm.InternalLinkage)
+	exception.SetAlignment(16)
+	exception.SetThreadLocal(true)
+
+	panicexitType := llvm.FunctionType(llvm.VoidType(), nil, false)
+	panicexit := c.module.NamedFunction("runtime.panicexit")
+	if panicexit.IsNil() {
+		panicexit = llvm.AddFunction(c.module.Module, "runtime.panicexit", panicexitType)
+	}
+
+	// _Unwind_Reason_Code stop(int version, _Unwind_Action actions,
+	//     uint64 exceptionClass, struct _Unwind_Exception *exceptionObject,
+	//     struct _Unwind_Context *context, void *stop_parameter)
+	stopType := llvm.FunctionType(i32, []llvm.Type{
+		i32, i32, llvm.Int64Type(), i8ptr, i8ptr, i8ptr}, false)
+	stop := llvm.AddFunction(c.module.Module, "runtime.unwindstop", stopType)
+	stop.SetLinkage(llvm.InternalLinkage)
+	entry := llvm.AddBasicBlock(stop, "entry")
+	endOfStack := llvm.AddBasicBlock(stop, "endofstack")
+	cont := llvm.AddBasicBlock(stop, "continue")
+	c.builder.SetInsertPointAtEnd(entry)
+	const _UA_END_OF_STACK = 16
+	actions := c.builder.CreateAnd(stop.Param(1),
+		llvm.ConstInt(i32, _UA_END_OF_STACK, false), "")
+	isEnd := c.builder.CreateICmp(llvm.IntNE, actions, llvm.ConstNull(i32), "")
+	c.builder.CreateCondBr(isEnd, endOfStack, cont)
+	c.builder.SetInsertPointAtEnd(endOfStack)
+	c.builder.CreateCall(panicexit, nil, "")
+	c.builder.CreateUnreachable()
+	c.builder.SetInsertPointAtEnd(cont)
+	c.builder.CreateRet(llvm.ConstNull(i32)) // _URC_NO_REASON
+
+	forcedUnwind := c.module.NamedFunction("_Unwind_ForcedUnwind")
+	if forcedUnwind.IsNil() {
+		fntype := llvm.FunctionType(i32, []llvm.Type{
+			i8ptr, llvm.PointerType(stopType, 0), i8ptr}, false)
+		forcedUnwind = llvm.AddFunction(c.module.Module, "_Unwind_ForcedUnwind", fntype)
+		forcedUnwind.SetFunctionCallConv(llvm.CCallConv)
+	}
+	entry = llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	args := []llvm.Value{
+		c.builder.CreateBitCast(exception, i8ptr, ""), stop, llvm.ConstNull(i8ptr)}
+	c.builder.CreateCall(forcedUnwind, args, "")
+	// _Unwind_ForcedUnwind only returns if an error occurred.
+	c.builder.CreateCall(panicexit, nil, "")
+	c.builder.CreateUnreachable()
+}
+
+// defineExitFunction defines runtime.exit, which calls the C library's
+// exit function with the given status.
+func (c *compiler) defineExitFunction(fn llvm.Value) {
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	exit := c.module.NamedFunction("exit")
+	if exit.IsNil() {
+		fntype := llvm.FunctionType(llvm.VoidType(), []llvm.Type{llvm.Int32Type()}, false)
+		exit = llvm.AddFunction(c.module.Module, "exit", fntype)
+		exit.SetFunctionCallConv(llvm.CCallConv)
+	}
+	code := fn.Param(0)
+	if code.Type().IntTypeWidth() > 32 {
+		code = c.builder.CreateTrunc(code, llvm.Int32Type(), "")
+	}
+	c.builder.CreateCall(exit, []llvm.Value{code}, "")
+	c.builder.CreateUnreachable()
+}
+
 // vim: set ft=go:
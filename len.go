@@ -57,15 +57,19 @@ func (c *compiler) VisitLen(expr *ast.CallExpr) Value {
 		return c.NewConstValue(token.INT, v)
 
 	case *types.Slice:
-		ptr := value.(*LLVMValue).pointer
-		len_field := c.builder.CreateStructGEP(ptr.LLVMValue(), 1, "")
-		len_value := c.builder.CreateLoad(len_field, "")
+		len_value := c.builder.CreateExtractValue(value.LLVMValue(), 1, "")
 		return c.NewLLVMValue(len_value, types.Int32).Convert(types.Int)
 
 	case *types.Array:
 		v := strconv.FormatUint(typ.Len, 10)
 		return c.NewConstValue(token.INT, v)
 
+	case *types.Chan:
+		return c.chanLenCap(value.(*LLVMValue), "len")
+
+	case *types.Map:
+		return c.mapLen(value.(*LLVMValue))
+
 	case *types.Struct:
 		sz := llvm.SizeOf(c.types.ToLLVM(typ))
 		// FIXME
@@ -86,4 +90,30 @@ func (c *compiler) VisitLen(expr *ast.CallExpr) Value {
 	panic(fmt.Sprint("Unhandled value type: ", value.Type()))
 }
 
+func (c *compiler) VisitCap(expr *ast.CallExpr) Value {
+	if len(expr.Args) > 1 {
+		panic("Expecting only one argument to cap")
+	}
+
+	value := c.VisitExpr(expr.Args[0])
+	typ := value.Type()
+	if ptr, ok := types.Underlying(typ).(*types.Pointer); ok {
+		// cap(p) for a pointer to an array is the length of the array.
+		typ = ptr.Base
+	}
+	switch typ := types.Underlying(typ).(type) {
+	case *types.Slice:
+		cap_value := c.builder.CreateExtractValue(value.LLVMValue(), 2, "")
+		return c.NewLLVMValue(cap_value, types.Int32).Convert(types.Int)
+
+	case *types.Array:
+		v := strconv.FormatUint(typ.Len, 10)
+		return c.NewConstValue(token.INT, v)
+
+	case *types.Chan:
+		return c.chanLenCap(value.(*LLVMValue), "cap")
+	}
+	panic(fmt.Sprint("Unhandled value type: ", value.Type()))
+}
+
 // vim: set ft=go :
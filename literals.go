@@ -33,27 +33,65 @@ func (c *compiler) VisitBasicLit(lit *ast.BasicLit) Value {
 	return c.NewConstValue(lit.Kind, lit.Value)
 }
 
+// VisitFuncLit compiles a function literal, returning a func value. If the
+// function literal refers to variables of enclosing functions, then the
+// function takes an additional first parameter: a context structure which
+// holds pointers to the variables, and the func value is a closure.
 func (c *compiler) VisitFuncLit(lit *ast.FuncLit) Value {
 	fn_type := c.VisitFuncType(lit.Type)
-	fn := llvm.AddFunction(c.module.Module, "", c.types.ToLLVM(fn_type))
-	fn.SetFunctionCallConv(llvm.FastCallConv)
+	llvm_fn_type := c.types.ToLLVM(fn_type).StructElementTypes()[0].ElementType()
+	vars := c.freeVars(lit)
+	param_i := 0
+	if len(vars) > 0 {
+		ctx_element_types := make([]llvm.Type, len(vars))
+		for i, obj := range vars {
+			ptr := obj.Data.(*LLVMValue).pointer
+			ctx_element_types[i] = ptr.LLVMValue().Type()
+		}
+		ctx_type := llvm.StructType(ctx_element_types, false)
+		param_types := append([]llvm.Type{llvm.PointerType(ctx_type, 0)},
+			llvm_fn_type.ParamTypes()...)
+		llvm_fn_type = llvm.FunctionType(
+			llvm_fn_type.ReturnType(), param_types, false)
+		param_i++
+	}
+	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	fn.SetLinkage(llvm.InternalLinkage)
 
-	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+	currBlock := c.builder.GetInsertBlock()
 	entry := llvm.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 
-	fn_value := c.NewLLVMValue(fn, fn_type)
-	c.functions = append(c.functions, fn_value)
+	// Bind the variables of enclosing functions to the pointers in the
+	// context, for the duration of the function literal.
+	data := make([]interface{}, len(vars))
+	for i, obj := range vars {
+		data[i] = obj.Data
+		ptr := c.builder.CreateStructGEP(fn.Param(0), i, "")
+		ptr = c.builder.CreateLoad(ptr, "")
+		typ := obj.Data.(*LLVMValue).pointer.Type()
+		obj.Data = c.NewLLVMValue(ptr, typ).makePointee()
+	}
+	c.bindParams(fn_type, fn, param_i)
+
+	c.pushFunction(c.NewLLVMValue(fn, fn_type), lit.Body)
 	c.VisitBlockStmt(lit.Body)
-	if fn_type.Results == nil {
-		lasti := entry.LastInstruction()
-		if lasti.IsNil() || lasti.Opcode() != llvm.Ret {
-			// Assume nil return type, AST should be checked first.
+	lasti := c.builder.GetInsertBlock().LastInstruction()
+	if lasti.IsNil() || lasti.IsATerminatorInst().IsNil() {
+		if fn_type.Results == nil {
+			c.runDefers()
 			c.builder.CreateRetVoid()
+		} else {
+			c.builder.CreateUnreachable()
 		}
 	}
 	c.functions = c.functions[0 : len(c.functions)-1]
-	return fn_value
+	for i, obj := range vars {
+		obj.Data = data[i]
+	}
+
+	c.builder.SetInsertPointAtEnd(currBlock)
+	return c.makeClosure(fn, fn_type, vars)
 }
 
 func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
@@ -117,24 +155,17 @@ func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
 			llvm.ConstArray(c.types.ToLLVM(elttype), llvm_values), origtyp)
 
 	case *types.Slice:
-		ptr := c.builder.CreateMalloc(c.types.ToLLVM(typ), "")
-		length := llvm.ConstInt(llvm.Int32Type(), uint64(len(valuelist)), false)
-		valuesPtr := c.builder.CreateArrayMalloc(c.types.ToLLVM(typ.Elt), length, "")
-		//valuesPtr = c.builder.CreateBitCast(valuesPtr, llvm.PointerType(valuesPtr.Type(), 0), "")
-		// TODO check result of mallocs
-		c.builder.CreateStore(valuesPtr, c.builder.CreateStructGEP(ptr, 0, "")) // data
-		c.builder.CreateStore(length, c.builder.CreateStructGEP(ptr, 1, ""))    // len
-		c.builder.CreateStore(length, c.builder.CreateStructGEP(ptr, 2, ""))    // cap
-		null := llvm.ConstNull(c.types.ToLLVM(typ.Elt))
+		llvm_values := make([]llvm.Value, len(valuelist))
 		for i, value := range valuelist {
-			index := llvm.ConstInt(llvm.Int32Type(), uint64(i), false)
-			valuePtr := c.builder.CreateGEP(valuesPtr, []llvm.Value{index}, "")
 			if value == nil {
-				c.builder.CreateStore(null, valuePtr)
+				llvm_values[i] = llvm.ConstNull(c.types.ToLLVM(typ.Elt))
 			} else {
-				c.builder.CreateStore(value.Convert(typ.Elt).LLVMValue(), valuePtr)
+				llvm_values[i] = value.Convert(typ.Elt).LLVMValue()
 			}
 		}
+		slice := c.makeLiteralSlice(llvm_values, origtyp)
+		ptr := c.builder.CreateMalloc(c.types.ToLLVM(typ), "")
+		c.builder.CreateStore(slice.LLVMValue(), ptr)
 		m := c.NewLLVMValue(ptr, &types.Pointer{Base: origtyp})
 		return m.makePointee()
 
@@ -166,9 +197,15 @@ func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
 		return m.makePointee()
 
 	case *types.Map:
-		value := llvm.ConstNull(c.types.ToLLVM(typ))
-		// TODO initialise map
-		return c.NewLLVMValue(value, origtyp)
+		n := llvm.ConstInt(c.target.IntPtrType(), uint64(len(valuemap)), false)
+		hint := c.NewLLVMValue(n, types.Int)
+		m := c.makeMap(origtyp, hint)
+		for key, value := range valuemap {
+			elem := c.mapInsert(m, key)
+			value = value.Convert(typ.Elt)
+			c.builder.CreateStore(value.LLVMValue(), elem.pointer.LLVMValue())
+		}
+		return m
 	}
 	panic(fmt.Sprint("Unhandled type kind: ", typ))
 }
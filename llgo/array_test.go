@@ -19,4 +19,12 @@ func TestArrayIndexing(t *testing.T) {
 	}
 }
 
+// Test slice expressions on arrays, slices and strings.
+func TestSliceExpr(t *testing.T) {
+	err := runAndCheckMain(testdata("slice_expr.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
@@ -12,4 +12,20 @@ func TestNew(t *testing.T) {
 	}
 }
 
+// Test use of the "append", "copy" and "cap" builtin functions.
+func TestAppend(t *testing.T) {
+	err := runAndCheckMain(testdata("append.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test use of the "make" builtin function with slices.
+func TestMakeSlice(t *testing.T) {
+	err := runAndCheckMain(testdata("make.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
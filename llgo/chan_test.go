@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestBufferedChan(t *testing.T) {
+	err := runAndCheckMain(testdata("chan/buffered.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChanClose(t *testing.T) {
+	err := runAndCheckMain(testdata("chan/close.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	err := runAndCheckMain(testdata("chan/select.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectUnbuffered(t *testing.T) {
+	err := runAndCheckMain(testdata("chan/select_unbuffered.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendClosedChan(t *testing.T) {
+	err := runAndCheckMain(testdata("chan/send_closed.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
@@ -18,4 +18,18 @@ func TestVarargsFunction(t *testing.T) {
 	}
 }
 
+func TestClosure(t *testing.T) {
+	err := runAndCheckMain(testdata("closure.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMethodValues(t *testing.T) {
+	err := runAndCheckMain(testdata("methods/values.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
@@ -25,4 +25,53 @@ func TestStaticBasicTypeToInterfaceConversion(t *testing.T) {
 	}
 }
 
+func TestTypeAssertion(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_assert.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceValueReceivers(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_methods.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDynamicInterfaceConversion(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_dynamic.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceMethodTables(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_itab.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceDynamicKinds(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_kinds.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceMethodMismatch(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_mismatch.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceSubsetConversion(t *testing.T) {
+	err := runAndCheckMain(testdata("interface_subset.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
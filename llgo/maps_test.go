@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestMaps(t *testing.T) {
+	err := runAndCheckMain(testdata("maps/maps.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	err := runAndCheckMain(testdata("maps/keys.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapIteration(t *testing.T) {
+	err := runAndCheckMain(testdata("maps/iterate.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
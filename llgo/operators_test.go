@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestIntegerOperators(t *testing.T) {
+	err := runAndCheckMain(testdata("operators/integer.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFloatOperators(t *testing.T) {
+	err := runAndCheckMain(testdata("operators/float.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComplexOperators(t *testing.T) {
+	err := runAndCheckMain(testdata("operators/complex.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEqualityOperators(t *testing.T) {
+	err := runAndCheckMain(testdata("operators/equality.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRange(t *testing.T) {
+	err := runAndCheckMain(testdata("range/range.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
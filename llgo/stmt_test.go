@@ -11,6 +11,13 @@ func TestMultipleAssigment(t *testing.T) {
 	}
 }
 
+func TestCompoundAssignment(t *testing.T) {
+	err := runAndCheckMain(testdata("assignop.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestEmptySwitch(t *testing.T) {
 	err := runAndCheckMain(testdata("switch/empty.go"), checkStringsEqual)
 	if err != nil {
@@ -25,6 +32,13 @@ func TestSwitchScope(t *testing.T) {
 	}
 }
 
+func TestTypeSwitch(t *testing.T) {
+	err := runAndCheckMain(testdata("switch/type.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestIfLazy(t *testing.T) {
 	err := runAndCheckMain(testdata("if/lazy.go"), checkStringsEqual)
 	if err != nil {
@@ -32,4 +46,46 @@ func TestIfLazy(t *testing.T) {
 	}
 }
 
+func TestDefer(t *testing.T) {
+	err := runAndCheckMain(testdata("defer/basic.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	err := runAndCheckMain(testdata("defer/recover.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverResults(t *testing.T) {
+	err := runAndCheckMain(testdata("defer/results.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverGoroutines(t *testing.T) {
+	err := runAndCheckMain(testdata("defer/goroutines.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeferBuiltins(t *testing.T) {
+	err := runAndCheckMain(testdata("defer/builtins.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBranch(t *testing.T) {
+	err := runAndCheckMain(testdata("branch/branch.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
@@ -18,4 +18,18 @@ func TestStringComparison(t *testing.T) {
 	}
 }
 
+func TestStringIndex(t *testing.T) {
+	err := runAndCheckMain(testdata("strings/index.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStringConversion(t *testing.T) {
+	err := runAndCheckMain(testdata("strings/conv.go"), checkStringsEqual)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // vim: set ft=go:
@@ -0,0 +1,47 @@
+package main
+
+func main() {
+    var s []int
+    println(len(s), cap(s))
+    for i := 0; i < 10; i++ {
+        s = append(s, i)
+    }
+    println(len(s), s[0], s[9])
+    println(cap(s) >= len(s))
+
+    s = append(s, 10, 11, 12)
+    println(len(s), s[10], s[12])
+
+    t := []int{100, 200}
+    s = append(s, t...)
+    println(len(s), s[13], s[14])
+
+    // Appending a slice to itself.
+    u := []int{1, 2, 3}
+    u = append(u, u...)
+    println(len(u), u[0], u[3], u[5])
+
+    b := []byte{'a'}
+    b = append(b, "bcd"...)
+    println(len(b), b[0], b[3])
+
+    // copy
+    dst := []int{0, 0, 0, 0, 0}
+    n := copy(dst, []int{7, 8, 9})
+    println(n, dst[0], dst[1], dst[2], dst[3])
+    n = copy(dst, u)
+    println(n, dst[0], dst[4])
+    n = copy(b, "xy")
+    println(n, b[0], b[1], b[2])
+
+    o := []int{1, 2, 3, 4}
+    copy(dst, o)
+    n = copy(o, dst)
+    println(n, o[0], o[3])
+
+    // cap
+    var a [5]int
+    println(cap(a), cap(&a), cap(t))
+    c := make(chan int, 3)
+    println(cap(c))
+}
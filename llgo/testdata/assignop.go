@@ -0,0 +1,68 @@
+package main
+
+type point struct {
+    x, y int
+}
+
+var calls int
+
+func index() int {
+    calls++
+    return 1
+}
+
+func main() {
+    x := 10
+    x += 5
+    x -= 3
+    x *= 4
+    x /= 3
+    x %= 7
+    println(x)
+    x = 0xff
+    x &= 0x3c
+    x |= 0x100
+    x ^= 0x11
+    x &^= 0x4
+    println(x)
+    x <<= 3
+    println(x)
+    x >>= 2
+    println(x)
+
+    var u uint8 = 200
+    u += 100
+    println(u)
+    u >>= 1
+    println(u)
+
+    s := "foo"
+    s += "bar"
+    println(s)
+
+    f := 1.5
+    f *= 4
+    f++
+    println(f == 7)
+
+    m := map[string]int{"a": 1}
+    m["a"] += 10
+    m["b"] += 2
+    m["c"]++
+    println(m["a"], m["b"], m["c"], len(m))
+
+    p := &point{1, 2}
+    p.x += 10
+    p.y *= 3
+    println(p.x, p.y)
+
+    a := []int{1, 2, 3}
+    a[index()] += 40
+    a[index()] -= 2
+    a[2]--
+    println(a[0], a[1], a[2], calls)
+
+    var arr [3]int
+    arr[index()] |= 6
+    println(arr[1], calls)
+}
@@ -0,0 +1,86 @@
+package main
+
+func loops() {
+    for i := 0; i < 10; i++ {
+        if i == 2 {
+            continue
+        }
+        if i == 5 {
+            break
+        }
+        println("loop", i)
+    }
+
+    i := 0
+    for {
+        i++
+        if i > 3 {
+            break
+        }
+        println("infinite", i)
+    }
+}
+
+func labels() {
+outer:
+    for i := 0; i < 3; i++ {
+        for j := 0; j < 3; j++ {
+            if j == 2 {
+                continue outer
+            }
+            if i == 2 {
+                break outer
+            }
+            println("nested", i, j)
+        }
+    }
+
+    for i := 0; i < 3; i++ {
+        switch i {
+        case 1:
+            break
+        default:
+            println("switch", i)
+        }
+    }
+
+loop:
+    for i := 0; i < 3; i++ {
+        switch i {
+        case 1:
+            break loop
+        }
+        println("labeled switch", i)
+    }
+
+    ch := make(chan int, 1)
+    for i := 0; i < 3; i++ {
+        ch <- i
+        select {
+        case v := <-ch:
+            if v == 1 {
+                break
+            }
+            println("select", v)
+        }
+    }
+}
+
+func gotos() {
+    i := 0
+again:
+    if i < 3 {
+        println("goto", i)
+        i++
+        goto again
+    }
+    goto done
+done:
+    println("done")
+}
+
+func main() {
+    loops()
+    labels()
+    gotos()
+}
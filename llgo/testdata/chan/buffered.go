@@ -0,0 +1,14 @@
+package main
+
+func main() {
+    c := make(chan int, 3)
+    println(len(c), cap(c))
+    c <- 1
+    c <- 2
+    println(len(c), cap(c))
+    println(<-c)
+    c <- 3
+    c <- 4
+    println(<-c, <-c, <-c)
+    println(len(c))
+}
@@ -0,0 +1,18 @@
+package main
+
+func send(c chan<- int, v int) {
+    c <- v
+}
+
+func recv(c <-chan int) {
+    v, ok := <-c
+    println(v, ok)
+}
+
+func main() {
+    c := make(chan int, 2)
+    send(c, 123)
+    close(c)
+    recv(c)
+    recv(c)
+}
@@ -0,0 +1,65 @@
+package main
+
+func sum(c chan int) int {
+    total := 0
+    for {
+        select {
+        case v, ok := <-c:
+            if !ok {
+                return total
+            }
+            total = total + v
+        }
+    }
+    return total
+}
+
+func main() {
+    c1 := make(chan int, 1)
+    c2 := make(chan int, 1)
+
+    // Nothing is ready, so the default clause is chosen.
+    select {
+    case v := <-c1:
+        println("received", v)
+    default:
+        println("default")
+    }
+
+    // Only the send case is ready.
+    select {
+    case c1 <- 123:
+        println("sent")
+    case v := <-c2:
+        println("received", v)
+    }
+
+    // Only the receive case is ready.
+    var v int
+    select {
+    case c1 <- 456:
+        println("sent")
+    case v = <-c1:
+        println("received", v)
+    default:
+        println("default")
+    }
+
+    // Nil channels are never ready.
+    var nilchan chan int
+    select {
+    case nilchan <- 1:
+        println("sent")
+    case <-nilchan:
+        println("received")
+    default:
+        println("default")
+    }
+
+    c3 := make(chan int, 3)
+    c3 <- 1
+    c3 <- 2
+    c3 <- 3
+    close(c3)
+    println(sum(c3))
+}
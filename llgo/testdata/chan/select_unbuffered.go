@@ -0,0 +1,38 @@
+package main
+
+func receive(c1, c2 chan int, done chan int) {
+    select {
+    case v := <-c1:
+        done <- v
+    case v := <-c2:
+        done <- v
+    }
+}
+
+func main() {
+    c1 := make(chan int)
+    c2 := make(chan int)
+    done := make(chan int)
+    go receive(c1, c2, done)
+
+    // Spin until the receiving select statement is waiting, and takes
+    // the value.
+    sent := false
+    for !sent {
+        select {
+        case c1 <- 1:
+            sent = true
+        default:
+        }
+    }
+
+    // The receiving select statement has committed to the first send, so
+    // a second send must not proceed.
+    select {
+    case c2 <- 2:
+        println("sent twice")
+    default:
+        println("default")
+    }
+    println(<-done)
+}
@@ -0,0 +1,22 @@
+package main
+
+// send blocks sending on an unbuffered channel, which is closed before the
+// value is received. The send panics.
+func send(c chan int, done chan bool) {
+    defer func() {
+        done <- recover() != nil
+    }()
+    c <- 123
+}
+
+func main() {
+    c := make(chan int)
+    done := make(chan bool)
+    go send(c, done)
+    close(c)
+    println(<-done)
+
+    // The value sent must not be received after the channel is closed.
+    v, ok := <-c
+    println(v, ok)
+}
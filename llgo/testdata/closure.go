@@ -0,0 +1,50 @@
+package main
+
+func counter() (func() int) {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func adder(x int) (func(int) int) {
+	return func(y int) int {
+		return x + y
+	}
+}
+
+func apply(f func(int) int, x int) int {
+	return f(x)
+}
+
+func main() {
+	c := counter()
+	println(c())
+	println(c())
+	println(c())
+
+	add5 := adder(5)
+	println(add5(10))
+	println(apply(add5, 1))
+	println(apply(adder(2), 3))
+
+	// Nested closures share the variables they capture.
+	x := 1
+	f := func() (func()) {
+		return func() {
+			x = x * 2
+		}
+	}
+	g := f()
+	g()
+	g()
+	println(x)
+
+	var h func()
+	println(h == nil)
+	h = g
+	println(h == nil)
+}
+
+// vim: set ft=go :
@@ -0,0 +1,48 @@
+package main
+
+type T struct {
+    name string
+}
+
+func (t T) greet(s string) {
+    println(s, t.name)
+}
+
+func deferred(i int) {
+    println("deferred", i)
+}
+
+func loop() {
+    for i := 0; i < 3; i++ {
+        defer deferred(i)
+    }
+    println("loop done")
+}
+
+func early(b bool) int {
+    defer deferred(100)
+    if b {
+        return 1
+    }
+    defer deferred(200)
+    return 2
+}
+
+func main() {
+    x := 1
+    defer deferred(x)
+    x = 2
+    defer deferred(x)
+
+    t := T{"world"}
+    defer t.greet("hello")
+    t.name = "changed"
+
+    defer func(s string) {
+        println("literal", s)
+    }("arg")
+
+    loop()
+    println(early(true))
+    println(early(false))
+}
@@ -0,0 +1,42 @@
+package main
+
+func closer(c chan int) {
+    defer close(c)
+    c <- 1
+}
+
+func deleter(m map[string]int, k string) {
+    defer delete(m, k)
+    println("before delete", len(m))
+}
+
+func printer() {
+    i := 1
+    defer println("deferred", i, "x")
+    i = 2
+    println("printer", i)
+}
+
+func panicker() {
+    defer func() {
+        println("recovered", recover().(string))
+    }()
+    defer panic("deferred panic")
+    println("panicker")
+}
+
+func main() {
+    c := make(chan int, 1)
+    closer(c)
+    v, ok := <-c
+    println(v, ok)
+    v, ok = <-c
+    println(v, ok)
+
+    m := map[string]int{"a": 1, "b": 2}
+    deleter(m, "a")
+    println("after delete", len(m), m["b"])
+
+    printer()
+    panicker()
+}
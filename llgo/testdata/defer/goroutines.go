@@ -0,0 +1,33 @@
+package main
+
+// worker panics, and blocks in a deferred call until told to finish. The
+// panic belongs to the worker goroutine, and is recovered there.
+func worker(started, finish chan int, done chan string) {
+    defer func() {
+        done <- recover().(string)
+    }()
+    defer func() {
+        started <- 1
+        <-finish
+    }()
+    panic("worker")
+}
+
+// check recovers while the worker is panicking; main is not panicking, so
+// there is nothing to recover.
+func check() {
+    defer func() {
+        println("main recovered nil:", recover() == nil)
+    }()
+}
+
+func main() {
+    started := make(chan int)
+    finish := make(chan int)
+    done := make(chan string)
+    go worker(started, finish, done)
+    <-started
+    check()
+    finish <- 1
+    println(<-done)
+}
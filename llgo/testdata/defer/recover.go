@@ -0,0 +1,55 @@
+package main
+
+func deferred(i int) {
+    println("deferred", i)
+}
+
+func f() {
+    defer func() {
+        r := recover()
+        println("recovered", r.(string))
+    }()
+    defer deferred(1)
+    println("before panic")
+    panic("oops")
+    println("after panic")
+}
+
+func g() int {
+    defer func() {
+        recover()
+    }()
+    panic(123)
+    return 1
+}
+
+func h() {
+    defer deferred(2)
+    panic("unwinding")
+}
+
+func nested() {
+    defer func() {
+        println("recovered", recover() != nil)
+    }()
+    h()
+    println("not reached")
+}
+
+func closedchan() {
+    defer func() {
+        println("recovered", recover() != nil)
+    }()
+    c := make(chan int, 1)
+    close(c)
+    c <- 1
+}
+
+func main() {
+    println(recover() == nil)
+    f()
+    println(g())
+    nested()
+    closedchan()
+    println("done")
+}
@@ -0,0 +1,57 @@
+package main
+
+func divide(a, b int) (q int, err string) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = "recovered"
+        }
+    }()
+    q = -1
+    q = a / b
+    return q, ""
+}
+
+func double() (x int) {
+    defer func() {
+        x *= 2
+    }()
+    return 21
+}
+
+func swap() (a, b int) {
+    a, b = 1, 2
+    return b, a
+}
+
+func bare() (a int, b string) {
+    a = 3
+    b = "bare"
+    return
+}
+
+func helper() bool {
+    return recover() != nil
+}
+
+func indirect() (recovered bool) {
+    defer func() {
+        recover()
+    }()
+    defer func() {
+        recovered = helper()
+    }()
+    panic("indirect")
+}
+
+func main() {
+    q, err := divide(7, 2)
+    println(q, err)
+    q, err = divide(7, 0)
+    println(q, err)
+    println(double())
+    a, b := swap()
+    println(a, b)
+    c, d := bare()
+    println(c, d)
+    println(indirect())
+}
@@ -0,0 +1,64 @@
+package main
+
+type Stringer interface {
+    String() string
+}
+
+type Name string
+
+type Named struct {
+    name string
+}
+
+func (n *Named) String() string {
+    return n.name
+}
+
+// Package-level variables may also be initialised with comma-ok forms.
+var global interface{} = 789
+var gi, gok = global.(int)
+var gs, gsok = global.(string)
+var gm = map[string]int{"a": 1}
+var gv, gvok = gm["a"]
+var _, gmissing = gm["b"]
+
+func assertInt(x interface{}) {
+    defer func() {
+        println("recovered", recover() != nil)
+    }()
+    println(x.(int))
+}
+
+func main() {
+    var x interface{} = 123
+    i, ok := x.(int)
+    println(i, ok)
+    s, ok := x.(string)
+    println(s, ok)
+
+    var y interface{} = Name("abc")
+    var n, isname = y.(Name)
+    println(n, isname)
+    var z interface{} = &Named{"def"}
+    str, isstringer := z.(Stringer)
+    println(str.String(), isstringer)
+    str, isstringer = x.(Stringer)
+    println(str == nil, isstringer)
+
+    c := make(chan int, 1)
+    c <- 456
+    close(c)
+    v, ok := <-c
+    println(v, ok)
+    v, ok = <-c
+    println(v, ok)
+
+    assertInt(x)
+    assertInt(y)
+    assertInt(nil)
+
+    println(gi, gok)
+    println(gs, gsok)
+    println(gv, gvok)
+    println(gmissing)
+}
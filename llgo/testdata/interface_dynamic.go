@@ -0,0 +1,94 @@
+package main
+
+type Reader interface {
+    Read() int
+}
+
+type Writer interface {
+    Write(n int)
+}
+
+type ReadWriter interface {
+    Read() int
+    Write(n int)
+}
+
+type WriterTo interface {
+    WriteTo(w Writer)
+}
+
+type Buffer struct {
+    n int
+}
+
+func (b *Buffer) Read() int {
+    return b.n
+}
+
+func (b *Buffer) Write(n int) {
+    b.n += n
+}
+
+func (b *Buffer) WriteTo(w Writer) {
+    w.Write(b.n)
+}
+
+type Const int
+
+func (c Const) Read() int {
+    return int(c)
+}
+
+func newReadWriter(n int) ReadWriter {
+    return &Buffer{n}
+}
+
+func transfer(r Reader, w Writer) {
+    if wt, ok := r.(WriterTo); ok {
+        println("using WriteTo")
+        wt.WriteTo(w)
+        return
+    }
+    println("using Read")
+    w.Write(r.Read())
+}
+
+func mustWriter(r Reader) {
+    defer func() {
+        if recover() != nil {
+            println("not a Writer")
+        }
+    }()
+    w := r.(Writer)
+    w.Write(1)
+    println("wrote")
+}
+
+func main() {
+    dst := &Buffer{}
+    transfer(&Buffer{3}, dst)
+    transfer(Const(4), dst)
+    println(dst.n)
+
+    var r Reader = newReadWriter(10)
+    w := r.(Writer)
+    w.Write(5)
+    println(r.Read())
+    if _, ok := r.(*Buffer); ok {
+        println("dynamic type preserved")
+    }
+
+    mustWriter(dst)
+    mustWriter(Const(1))
+    mustWriter(nil)
+
+    var e interface{} = Const(7)
+    if r, ok := e.(Reader); ok {
+        println(r.Read())
+    }
+    _, ok := e.(Writer)
+    println(ok)
+    var rw ReadWriter = dst
+    var rd Reader = rw
+    println(rd.(ReadWriter).Read())
+}
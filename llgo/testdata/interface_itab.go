@@ -0,0 +1,78 @@
+package main
+
+type Shape interface {
+    Area() int
+    Perimeter() int
+    Name() string
+    Scale(n int)
+}
+
+type Namer interface {
+    Name() string
+}
+
+type Rect struct {
+    w, h int
+}
+
+func (r *Rect) Area() int {
+    return r.w * r.h
+}
+
+func (r *Rect) Perimeter() int {
+    return 2 * (r.w + r.h)
+}
+
+func (r *Rect) Name() string {
+    return "rect"
+}
+
+func (r *Rect) Scale(n int) {
+    r.w *= n
+    r.h *= n
+}
+
+type Label string
+
+func (l Label) Name() string {
+    return string(l)
+}
+
+func describe(n Namer) {
+    switch v := n.(type) {
+    case nil:
+        println("nil")
+    case Shape:
+        println(v.Name(), v.Area(), v.Perimeter())
+    case Label:
+        println("label", string(v))
+    }
+}
+
+func main() {
+    r := &Rect{2, 3}
+    var s Shape = r
+    s.Scale(2)
+    println(s.Area(), s.Perimeter(), r.w, r.h)
+
+    var n Namer = s
+    println(n.Name())
+    var e interface{} = n
+    if s2, ok := e.(Shape); ok {
+        println(s2 == s, s2.Area())
+    }
+    println(e == interface{}(r), n == Namer(r))
+
+    var l Namer = Label("x")
+    println(l == Namer(Label("x")), l == n)
+
+    describe(n)
+    describe(l)
+    describe(nil)
+
+    var nilshape Shape
+    n = nilshape
+    println(n == nil)
+    _, ok := e.(Namer)
+    println(ok)
+}
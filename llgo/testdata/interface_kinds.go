@@ -0,0 +1,75 @@
+package main
+
+type T struct {
+    a int
+    b string `json:"b"`
+}
+
+type List struct {
+    next  *List
+    value int
+}
+
+type Op func(int, int) int
+
+type Stringer interface {
+    String() string
+}
+
+func (t T) String() string {
+    return t.b
+}
+
+func describe(x interface{}) {
+    switch v := x.(type) {
+    case nil:
+        println("nil")
+    case []int:
+        println("[]int", len(v), v[0])
+    case [3]string:
+        println("[3]string", v[0], v[2])
+    case func(int) int:
+        println("func(int) int", v(2))
+    case Op:
+        println("Op", v(3, 4))
+    case chan int:
+        println("chan int", cap(v))
+    case map[string]T:
+        println("map[string]T", len(v), v["x"].b)
+    case *List:
+        println("*List", v.value, v.next.value)
+    case struct{ x, y int }:
+        println("struct", v.x, v.y)
+    case Stringer:
+        println("Stringer", v.String())
+    case []interface{}:
+        println("[]interface{}", len(v))
+        for _, e := range v {
+            describe(e)
+        }
+    default:
+        println("unknown")
+    }
+}
+
+func main() {
+    describe([]int{7, 8})
+    describe([3]string{"a", "b", "c"})
+    describe(func(x int) int { return x * 2 })
+    describe(Op(func(a, b int) int { return a * b }))
+    describe(make(chan int, 5))
+    describe(map[string]T{"x": T{1, "one"}})
+    describe(&List{&List{nil, 2}, 1})
+    describe(struct{ x, y int }{3, 4})
+    describe(T{2, "two"})
+    describe([]interface{}{1, "two", []int{3}, nil})
+    describe([2]int{})
+    describe(nil)
+
+    var ops []Op
+    var x interface{} = ops
+    _, ok := x.([]Op)
+    println(ok)
+    _, ok = x.([]func(int, int) int)
+    println(ok)
+}
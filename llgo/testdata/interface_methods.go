@@ -0,0 +1,78 @@
+package main
+
+type Describer interface {
+    Describe() int
+}
+
+type Large struct {
+    a, b, c, d int64
+}
+
+func (l Large) Describe() int {
+    return int(l.a + l.b + l.c + l.d)
+}
+
+type Small struct {
+    x int16
+    y int8
+}
+
+func (s Small) Describe() int {
+    return int(s.x)*10 + int(s.y)
+}
+
+type Flag bool
+
+func (f Flag) Describe() int {
+    if f {
+        return 1
+    }
+    return 0
+}
+
+type Empty struct{}
+
+func (e Empty) Describe() int {
+    return 42
+}
+
+type Counter struct {
+    n int
+}
+
+func (c *Counter) Describe() int {
+    c.n++
+    return c.n
+}
+
+func describe(d Describer) {
+    println(d.Describe())
+}
+
+func main() {
+    l := Large{1, 2, 3, 4}
+    describe(l)
+    l.a = 100
+    describe(&l)
+
+    s := Small{7, 3}
+    describe(s)
+    describe(&s)
+
+    describe(Flag(true))
+    describe(Flag(false))
+    describe(Empty{})
+
+    c := &Counter{}
+    describe(c)
+    describe(c)
+
+    var d Describer = Large{5, 6, 7, 8}
+    l2 := d.(Large)
+    println(l2.a, l2.d)
+    d = Small{-2, 9}
+    s2 := d.(Small)
+    println(s2.x, s2.y)
+    f := d.Describe
+    println(f())
+}
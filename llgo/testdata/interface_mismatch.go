@@ -0,0 +1,27 @@
+package main
+
+type Writer interface {
+    Write(b []byte) int
+}
+
+type intWriter int
+
+func (w intWriter) Write(n int) int {
+    return n
+}
+
+type byteWriter int
+
+func (w byteWriter) Write(b []byte) int {
+    return len(b)
+}
+
+func main() {
+    var x interface{} = intWriter(1)
+    _, ok := x.(Writer)
+    println(ok)
+
+    x = byteWriter(1)
+    w, ok2 := x.(Writer)
+    println(ok2, w.Write([]byte("abc")))
+}
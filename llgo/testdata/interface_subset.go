@@ -0,0 +1,39 @@
+package main
+
+type Reader interface {
+    Read() string
+}
+
+type Writer interface {
+    Write(s string)
+}
+
+type ReadWriter interface {
+    Read() string
+    Write(s string)
+}
+
+type buffer struct {
+    data string
+}
+
+func (b *buffer) Read() string {
+    return b.data
+}
+
+func (b *buffer) Write(s string) {
+    b.data += s
+}
+
+func main() {
+    var rw ReadWriter = &buffer{}
+    var w Writer = rw
+    w.Write("hello")
+    var r Reader = rw
+    println(r.Read())
+
+    rw = nil
+    r = rw
+    w = rw
+    println(r == nil, w == nil)
+}
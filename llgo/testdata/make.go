@@ -0,0 +1,29 @@
+package main
+
+func makeslice(n, m int) {
+    defer func() {
+        println(recover() != nil)
+    }()
+    s := make([]int, n, m)
+    println(len(s), cap(s))
+}
+
+func main() {
+    s := make([]int, 3)
+    println(len(s), cap(s), s[0], s[1], s[2])
+    s[1] = 5
+    println(s[1])
+
+    t := make([]string, 2, 10)
+    println(len(t), cap(t), t[0] == "", t[1] == "")
+    t = append(t, "abc")
+    println(len(t), cap(t), t[2])
+
+    var n int = 4
+    u := make([]byte, n, n*2)
+    println(len(u), cap(u), u[3])
+
+    makeslice(1, 2)
+    makeslice(-1, 2)
+    makeslice(3, 2)
+}
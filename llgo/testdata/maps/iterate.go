@@ -0,0 +1,51 @@
+package main
+
+// grow inserts entries while ranging over a map, enough for the map to
+// grow. The entries present when the range began must each be produced
+// exactly once.
+func grow() {
+    m := make(map[int]int)
+    for i := 0; i < 8; i++ {
+        m[i] = i
+    }
+    seen := make(map[int]int)
+    for k := range m {
+        seen[k] = seen[k] + 1
+        if k < 8 {
+            for j := 0; j < 16; j++ {
+                m[100+k*16+j] = j
+            }
+        }
+    }
+    for i := 0; i < 8; i++ {
+        println(i, seen[i])
+    }
+    twice := false
+    for _, n := range seen {
+        if n > 1 {
+            twice = true
+        }
+    }
+    println("twice:", twice, len(m))
+}
+
+// shrink deletes the other entries when the first is produced. Deleted
+// entries must not be produced.
+func shrink() {
+    m := map[int]int{1: 1, 2: 2, 3: 3, 4: 4}
+    count := 0
+    for k := range m {
+        count++
+        for j := 1; j <= 4; j++ {
+            if j != k {
+                delete(m, j)
+            }
+        }
+    }
+    println(count, len(m))
+}
+
+func main() {
+    grow()
+    shrink()
+}
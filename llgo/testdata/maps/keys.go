@@ -0,0 +1,51 @@
+package main
+
+type Point struct {
+    x, y int
+}
+
+type Set map[int]bool
+
+func insert(m map[interface{}]int, k interface{}) {
+    defer func() {
+        if recover() != nil {
+            println("panicked")
+        }
+    }()
+    m[k] = 1
+    println("inserted")
+}
+
+func main() {
+    points := make(map[Point]string)
+    points[Point{1, 2}] = "a"
+    points[Point{2, 1}] = "b"
+    points[Point{1, 2}] = "c"
+    println(len(points), points[Point{1, 2}], points[Point{2, 1}])
+
+    strs := make(map[string]int)
+    strs["a"+"b"] = 1
+    strs["ab"]++
+    println(len(strs), strs["ab"])
+
+    floats := make(map[float64]int)
+    zero := 0.0
+    floats[zero] = 1
+    floats[-zero] = 2
+    nan := zero / zero
+    floats[nan] = 3
+    floats[nan] = 4
+    _, ok := floats[nan]
+    println(len(floats), floats[0], ok)
+
+    ifaces := make(map[interface{}]int)
+    ifaces[1] = 1
+    ifaces[int64(1)] = 2
+    ifaces["one"] = 3
+    ifaces[Point{1, 1}] = 4
+    ifaces[Point{1, 1}] += 10
+    println(len(ifaces), ifaces[1], ifaces[int64(1)], ifaces["one"], ifaces[Point{1, 1}])
+    insert(ifaces, nil)
+    insert(ifaces, Set{1: true})
+    println(len(ifaces))
+}
@@ -0,0 +1,62 @@
+package main
+
+type key struct {
+    a, b int
+}
+
+func nilassign() {
+    defer func() {
+        println("recovered", recover() != nil)
+    }()
+    var m map[int]int
+    m[1] = 1
+}
+
+func main() {
+    m := make(map[string]int)
+    m["one"] = 1
+    m["two"] = 2
+    m["three"] = 3
+    println(len(m), m["one"], m["two"], m["three"], m["four"])
+
+    m["two"] = 22
+    m["one"]++
+    println(len(m), m["one"], m["two"])
+
+    v, ok := m["three"]
+    println(v, ok)
+    v, ok = m["four"]
+    println(v, ok)
+
+    delete(m, "three")
+    delete(m, "four")
+    _, ok = m["three"]
+    println(len(m), ok)
+
+    // Growth.
+    n := make(map[int]int, 4)
+    for i := 0; i < 100; i++ {
+        n[i] = i * i
+    }
+    sum := 0
+    for k, v := range n {
+        if v != k*k {
+            println("mismatch", k, v)
+        }
+        sum = sum + v
+    }
+    println(len(n), sum, n[7], n[99])
+
+    // Composite literals, struct keys.
+    p := map[key]string{key{1, 2}: "a", key{3, 4}: "b"}
+    println(len(p), p[key{1, 2}], p[key{3, 4}], p[key{5, 6}] == "")
+
+    // Nil maps.
+    var nilmap map[string]bool
+    println(nilmap == nil, len(nilmap), nilmap["x"])
+    delete(nilmap, "x")
+    for k := range nilmap {
+        println(k)
+    }
+    nilassign()
+}
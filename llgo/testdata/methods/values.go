@@ -0,0 +1,87 @@
+package main
+
+type T struct {
+    n int
+}
+
+func (t T) Value(x int) int {
+    return t.n + x
+}
+
+func (t *T) Inc(x int) {
+    t.n += x
+}
+
+type E struct {
+    T
+    name string
+}
+
+func (t *T) Get() int {
+    return t.n
+}
+
+type Getter interface {
+    Get() int
+}
+
+type Celsius int
+
+func (c Celsius) Double() Celsius {
+    return c * 2
+}
+
+func apply(f func(int) int, x int) int {
+    return f(x)
+}
+
+func apply2(f func(int) int) func(int) int {
+    return func(x int) int {
+        return f(f(x))
+    }
+}
+
+func main() {
+    t := T{1}
+
+    // Method values capture the receiver when evaluated.
+    value := t.Value
+    inc := t.Inc
+    t.n = 10
+    println(value(1), t.Value(1))
+    inc(5)
+    println(t.n, value(0))
+    println(apply(t.Value, 100))
+
+    p := &t
+    pvalue := p.Value
+    p.n = 20
+    println(pvalue(0))
+
+    // Promoted methods.
+    e := E{T{3}, "e"}
+    evalue := e.Value
+    einc := e.Inc
+    einc(4)
+    println(evalue(0), e.n)
+
+    // Method expressions.
+    fv := T.Value
+    fi := (*T).Inc
+    fp := (*T).Value
+    fi(&t, 2)
+    println(fv(t, 1), fp(&t, 2))
+    println(E.Value(e, 1))
+    println(int(Celsius.Double(21)))
+
+    // Interface method values and expressions.
+    var g Getter = &t
+    get := g.Get
+    t.n = 5
+    println(get(), Getter.Get(g))
+
+    fs := []func(int) int{t.Value, e.Value, apply2(t.Value)}
+    for _, f := range fs {
+        println(f(1))
+    }
+}
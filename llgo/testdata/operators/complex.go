@@ -0,0 +1,46 @@
+package main
+
+const f32 float32 = 1.5
+const c64const complex64 = 1 + 2i
+
+func main() {
+    var a complex128 = complex(1, 2)
+    b := 3 + 4i
+    c := a * b
+    println(int(real(c)), int(imag(c)))
+    c = a + b
+    println(int(real(c)), int(imag(c)))
+    c = a - b
+    println(int(real(c)), int(imag(c)))
+    c = -a
+    println(int(real(c)), int(imag(c)))
+
+    q := b / a
+    println(real(q) == 2.2, imag(q) == -0.4)
+    println(a == complex(1, 2), a != complex(1, 2), a == b)
+
+    var zero complex128
+    inf := a / zero
+    println(real(inf) > 1e308, imag(inf) > 1e308)
+
+    var x float32 = 1.5
+    var y float32 = -0.5
+    c64 := complex(x, y)
+    println(real(c64) == x, imag(c64) == y)
+    c64 = c64 * c64
+    println(real(c64) == 2, imag(c64) == -1.5)
+    c64 = c64 / complex64(2i)
+    println(real(c64) == -0.75, imag(c64) == -1)
+
+    wide := complex128(c64)
+    println(real(wide) == -0.75, imag(wide) == -1)
+    println(real(2+3i) == 2, imag(2+3i) == 3)
+
+    // Typed constant arguments give typed constant results.
+    var i interface{} = complex(f32, 1)
+    _, ok := i.(complex64)
+    println(ok)
+    i = real(c64const)
+    _, ok = i.(float32)
+    println(ok)
+}
@@ -0,0 +1,72 @@
+package main
+
+type Point struct {
+    x, y int
+}
+
+type Named struct {
+    name  string
+    value interface{}
+}
+
+type Funcs map[string]int
+
+func compare(a, b interface{}) {
+    defer func() {
+        if recover() != nil {
+            println("panicked")
+        }
+    }()
+    println(a == b)
+}
+
+func main() {
+    // Structs and arrays.
+    p, q := Point{1, 2}, Point{1, 2}
+    println(p == q, p != q)
+    q.y = 3
+    println(p == q, p != q)
+
+    a := [3]string{"a", "b", "c"}
+    b := [3]string{"a", "b", "c"}
+    println(a == b)
+    b[2] = "d"
+    println(a == b, a != b)
+
+    n1 := Named{"x", 1}
+    n2 := Named{"x", 1}
+    println(n1 == n2)
+    n2.value = "1"
+    println(n1 == n2)
+
+    var f1, f2 [2]float64
+    f1[0] = 0
+    f2[0] = -f1[0]
+    println(f1 == f2)
+    zero := 0.0
+    f1[1] = zero / zero
+    f2[1] = f1[1]
+    println(f1 == f2)
+
+    // Interfaces.
+    var e1, e2 interface{} = "hello", "hel"
+    println(e1 == e2)
+    e2 = e2.(string) + "lo"
+    println(e1 == e2)
+    println(e1 == "hello", "hello" == e1, e1 != "world")
+
+    e1, e2 = Point{1, 2}, Point{1, 2}
+    println(e1 == e2, e1 == p)
+    e2 = Point{2, 1}
+    println(e1 == e2)
+    e1, e2 = 1, int64(1)
+    println(e1 == e2)
+    e2 = nil
+    println(e1 == e2, e2 == nil)
+
+    compare(1, 1)
+    compare(Funcs{}, 1)
+    compare(Funcs{}, Funcs{})
+    compare(Named{"f", Funcs{}}, Named{"f", Funcs{}})
+    compare(Named{"f", Funcs{}}, Named{"g", Funcs{}})
+}
@@ -0,0 +1,35 @@
+package main
+
+type celsius float64
+
+func main() {
+    var a, b float64 = 7.5, 2
+    println(int(a + b), int(a - b), int(a * b), int(a / b * 100))
+    println(a < b, a > b, a <= 7.5, a >= 7.5, a == 7.5, a != 7.5)
+    println(int(-a), int64(a), uint8(a))
+
+    var zero float64
+    nan := zero / zero
+    println(nan == nan, nan != nan, nan < a, nan > a, nan <= nan, nan >= nan)
+    inf := 1 / zero
+    println(inf > 1e308, -inf < -1e308)
+
+    // Constants are rounded once, from their exact values.
+    println(0.1+0.2 == 0.3, a*0.1 == 0.75)
+    var x float64 = 0.1
+    var y float64 = 0.2
+    println(x+y == 0.3)
+
+    var f32 float32 = 16777217
+    println(int(f32), int(float64(f32)))
+    var third float32 = 1.0 / 3
+    println(float64(third) == 1.0/3, float32(float64(third)) == third)
+
+    var i int = -3
+    var u uint = 3000000000
+    println(int(float64(i) * 1.5), int64(float64(u)))
+    println(int(2.0) + 1)
+
+    c := celsius(36.6)
+    println(c > 36, int(c*10))
+}
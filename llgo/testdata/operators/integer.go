@@ -0,0 +1,48 @@
+package main
+
+func divide(x, y int) {
+    defer func() {
+        println(recover() != nil)
+    }()
+    println(x / y, x % y)
+}
+
+func main() {
+    var a, b int = -7, 2
+    println(a / b, a % b, a < b, a > b, a <= b, a >= b)
+
+    var u, v uint = 7, 2
+    println(u / v, u % v)
+    var big uint32 = 0xffffffff
+    var small uint32 = 1
+    println(big > small, big < small)
+
+    var i8 int8 = -128
+    var m1 int8 = -1
+    println(i8 / m1, i8 % m1, -i8)
+    var i32 int32 = -2147483648
+    var n1 int32 = -1
+    println(i32 / n1, i32 % n1)
+
+    x, y := 0xf0, 0x3c
+    println(x & y, x | y, x ^ y, x &^ y, ^x)
+
+    var s int32 = -16
+    var shift uint = 2
+    println(s >> shift, s << shift, s >> 40, s << 40)
+    var w uint32 = 0x80000000
+    println(w >> 31, w >> 32, w << 1)
+    var by uint8 = 0x81
+    var c8 uint8 = 7
+    println(by >> c8, by << 1, by >> 100)
+    println(1 << shift, 1 << 10)
+
+    var i int8 = -3
+    var j int64 = int64(i)
+    var k uint8 = 200
+    var l int = int(k)
+    println(j, l, int32(i) < 0)
+
+    divide(7, 2)
+    divide(-7, 0)
+}
@@ -0,0 +1,34 @@
+package main
+
+func main() {
+    a := [3]int{1, 2, 3}
+    for i, v := range a {
+        println(i, v)
+    }
+    for i := range &a {
+        println(i)
+    }
+
+    s := []string{"a", "b", "c"}
+    for _, v := range s {
+        println(v)
+    }
+    var i int
+    var v string
+    for i, v = range s {
+    }
+    println(i, v)
+
+    for i, r := range "aé世\xff!" {
+        println(i, r)
+    }
+
+    c := make(chan int, 3)
+    c <- 10
+    c <- 20
+    c <- 30
+    close(c)
+    for v := range c {
+        println(v)
+    }
+}
@@ -0,0 +1,59 @@
+package main
+
+func slice(s []int, low, high int) {
+    defer func() {
+        println(recover() != nil)
+    }()
+    t := s[low:high]
+    println(len(t), cap(t))
+}
+
+func slicePointer(p *[3]int) {
+    defer func() {
+        println(recover() != nil)
+    }()
+    s := p[:]
+    println(len(s))
+}
+
+func main() {
+    var a [5]int
+    for i := 0; i < len(a); i++ {
+        a[i] = i * 10
+    }
+    s := a[1:3]
+    println(len(s), cap(s), s[0], s[1])
+    s[0] = 11
+    println(a[1])
+
+    p := &a
+    s = p[:2]
+    println(len(s), cap(s), s[0], s[1])
+    s = p[3:]
+    println(len(s), cap(s), s[0], s[1])
+
+    s = a[:]
+    println(len(s), cap(s))
+    t := s[1:2:3]
+    println(len(t), cap(t), t[0])
+    t = s[2:2]
+    println(len(t), cap(t))
+
+    // Slicing beyond the length, within the capacity.
+    t = s[1:2]
+    t = t[0:3]
+    println(len(t), cap(t), t[2])
+
+    str := "hello, world"
+    println(str[7:], str[:5], str[3:8], len(str[3:8]))
+    println(str[:])
+
+    slice(s, 0, 5)
+    slice(s, 2, 1)
+    slice(s, 0, 6)
+    slice(s, -1, 2)
+
+    var b [3]int
+    slicePointer(&b)
+    slicePointer(nil)
+}
@@ -0,0 +1,39 @@
+package main
+
+type bytes []byte
+type name string
+
+func main() {
+    s := "héllo"
+    b := []byte(s)
+    println(len(b), b[0], b[1], b[2])
+    b[0] = 'H'
+    println(string(b), s)
+
+    r := []rune(s)
+    println(len(r), r[0], r[1], r[4])
+    r[1] = 'e'
+    println(string(r))
+
+    println(len([]rune("a\xffb")), []rune("a\xffb")[1])
+    println(string([]rune{0x4e16, 0x754c, -1, 0xd800}))
+
+    var x int = 0x20ac
+    println(string(x), len(string(x)))
+    var c rune = 'A'
+    println(string(c))
+    x = -1
+    println(string(x) == "�")
+    x = 0x110000
+    println(string(x) == "�")
+    println(string(65), string(0x754c))
+
+    nb := bytes("abc")
+    println(len(nb), nb[2])
+    n := name(nb)
+    println(n == "abc")
+    println(string(n[1:]))
+
+    var empty []byte
+    println(string(empty) == "", len([]byte("")))
+}
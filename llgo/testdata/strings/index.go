@@ -0,0 +1,40 @@
+package main
+
+func index(s string, i int) {
+    defer func() {
+        println(recover() != nil)
+    }()
+    println(s[i])
+}
+
+type name string
+
+func main() {
+    s := "abc"
+    println(s[0], s[1], s[2])
+    var b byte = s[1]
+    b = b + 1
+    println(b, s[1])
+    println("xyz"[2])
+
+    n := name("hello")
+    println(n[4], len(n[1:3]))
+
+    // Substrings share storage with the original string.
+    t := s[1:]
+    println(t, t[0], len(t))
+
+    sum := 0
+    for i := 0; i < len(s); i++ {
+        sum = sum + int(s[i])
+    }
+    println(sum)
+
+    for i, r := range "x\xc3\xa9\xe2\x82\xac\xf0\x9f\x98\x80\xc3\xff\xed\xa0\x80" {
+        println(i, r)
+    }
+
+    index(s, 2)
+    index(s, 3)
+    index(s, -1)
+}
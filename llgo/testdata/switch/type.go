@@ -0,0 +1,75 @@
+package main
+
+type Numbered interface {
+    Number() int
+}
+
+type Named interface {
+    Name() string
+}
+
+type Beast struct {
+    name string
+}
+
+func (b *Beast) Number() int {
+    return 666
+}
+
+func (b *Beast) Name() string {
+    return b.name
+}
+
+type Counter int
+
+func (c *Counter) Number() int {
+    return int(*c)
+}
+
+func describe(x interface{}) {
+    switch v := x.(type) {
+    case nil:
+        println("nil")
+    case int:
+        println("int", v+1)
+    case string:
+        println("string", v)
+    case bool, uint8:
+        println("bool or uint8")
+    case Named:
+        println("named", v.Name())
+    case Numbered:
+        println("numbered", v.Number())
+    default:
+        println("unknown")
+    }
+}
+
+func main() {
+    describe(nil)
+    describe(123)
+    describe("abc")
+    describe(true)
+    describe(uint8(1))
+    describe(int32(2))
+    describe(&Beast{"beast"})
+    var c Counter = 42
+    describe(&c)
+
+    var n Numbered = &c
+    switch n.(type) {
+    default:
+        println("default first")
+    case *Beast:
+        println("beast")
+    case *Counter:
+        println("counter")
+    }
+    n = &Beast{"other"}
+    switch n := n.(type) {
+    case *Counter:
+        println("counter")
+    case Named:
+        println("named", n.Name())
+    }
+}
@@ -27,15 +27,33 @@ import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
+	"hash/fnv"
 	"reflect"
+	"strconv"
 )
 
+// Resolver is used by TypeMap to obtain the functions implementing
+// methods, when generating the method tables of runtime types.
+type Resolver interface {
+	Resolve(obj *ast.Object) Value
+
+	// InterfaceMethod returns the function called through an interface
+	// whose dynamic type is the method's receiver type (or a pointer to
+	// it, if ptr is true), for the method m.
+	InterfaceMethod(m *ast.Object, ptr bool) llvm.Value
+}
+
 type TypeMap struct {
-	module  llvm.Module
-	target  llvm.TargetData
-	types   map[types.Type]llvm.Type  // compile-time LLVM type
-	runtime map[types.Type]llvm.Value // runtime/reflect type representation
-	expr    map[ast.Expr]types.Type   // expression types
+	module   llvm.Module
+	target   llvm.TargetData
+	resolver Resolver
+	types    map[types.Type]llvm.Type   // compile-time LLVM type
+	runtime  map[string]llvm.Value      // runtime/reflect type representation, by typeKey
+	names    map[*ast.Object]int        // declaration numbers of named types
+	expr     map[ast.Expr]types.Type    // expression types
+	pkgmap   map[*ast.Object]string     // package names of global objects
+	pkgname  string                     // name of the package being compiled
+	algs     map[types.Type]*algorithms // algorithm functions
 
 	runtimeCommonType,
 	runtimeUncommonType,
@@ -54,10 +72,12 @@ type TypeMap struct {
 	copyAlgFunctionType llvm.Type
 }
 
-func NewTypeMap(module llvm.Module, target llvm.TargetData, exprTypes map[ast.Expr]types.Type) *TypeMap {
-	tm := &TypeMap{module: module, target: target, expr: exprTypes}
+func NewTypeMap(module llvm.Module, target llvm.TargetData, resolver Resolver, exprTypes map[ast.Expr]types.Type, pkgmap map[*ast.Object]string, pkgname string) *TypeMap {
+	tm := &TypeMap{module: module, target: target, resolver: resolver, expr: exprTypes, pkgmap: pkgmap, pkgname: pkgname}
 	tm.types = make(map[types.Type]llvm.Type)
-	tm.runtime = make(map[types.Type]llvm.Value)
+	tm.runtime = make(map[string]llvm.Value)
+	tm.names = make(map[*ast.Object]int)
+	tm.algs = make(map[types.Type]*algorithms)
 
 	// Load "reflect.go", and generate LLVM types for the runtime type
 	// structures.
@@ -80,21 +100,21 @@ func NewTypeMap(module llvm.Module, target llvm.TargetData, exprTypes map[ast.Ex
 	tm.runtimeSliceType = objToLLVMType("sliceType")
 	tm.runtimeStructType = objToLLVMType("structType")
 
-	// Types for algorithms. See 'runtime/runtime.h'.
+	// Types for algorithms. See 'runtime/alg.go'. The runtime represents
+	// unsafe.Pointer as uintptr.
 	uintptrType := tm.target.IntPtrType()
-	voidPtrType := llvm.PointerType(llvm.Int8Type(), 0)
 	boolType := llvm.Int1Type()
 
 	// Create runtime algorithm function types.
 	params := []llvm.Type{
-		llvm.PointerType(uintptrType, 0), uintptrType, voidPtrType}
+		llvm.PointerType(uintptrType, 0), uintptrType, uintptrType}
 	tm.hashAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
 	params = []llvm.Type{
-		llvm.PointerType(boolType, 0), uintptrType, voidPtrType, voidPtrType}
+		llvm.PointerType(boolType, 0), uintptrType, uintptrType, uintptrType}
 	tm.equalAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
-	params = []llvm.Type{uintptrType, voidPtrType}
+	params = []llvm.Type{uintptrType, uintptrType}
 	tm.printAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
-	params = []llvm.Type{uintptrType, voidPtrType, voidPtrType}
+	params = []llvm.Type{uintptrType, uintptrType, uintptrType}
 	tm.copyAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
 
 	return tm
@@ -114,14 +134,15 @@ func (tm *TypeMap) ToLLVM(t types.Type) llvm.Type {
 }
 
 func (tm *TypeMap) ToRuntime(t types.Type) llvm.Value {
-	t = types.Underlying(t)
-	r, ok := tm.runtime[t]
+	// Identical types must share a runtime type, so that the dynamic
+	// types of interface values may be compared by address; the runtime
+	// types are recorded by a key identifying the type.
+	r, ok := tm.runtime[tm.typeKey(t)]
 	if !ok {
 		r = tm.makeRuntimeType(t)
 		if r.IsNil() {
 			panic(fmt.Sprint("Failed to create runtime type for: ", t))
 		}
-		tm.runtime[t] = r
 	}
 	return r
 }
@@ -177,8 +198,12 @@ func (tm *TypeMap) basicLLVMType(b *types.Basic) llvm.Type {
 	case types.UnsafePointerKind, types.UintptrKind,
 		types.UintKind, types.IntKind:
 		return tm.target.IntPtrType()
-	//case Complex64: TODO
-	//case Complex128:
+	case types.Complex64Kind:
+		f32 := llvm.FloatType()
+		return llvm.StructType([]llvm.Type{f32, f32}, false)
+	case types.Complex128Kind:
+		f64 := llvm.DoubleType()
+		return llvm.StructType([]llvm.Type{f64, f64}, false)
 	//case UntypedInt:
 	//case UntypedFloat:
 	//case UntypedComplex:
@@ -253,47 +278,36 @@ func (tm *TypeMap) funcLLVMType(f *types.Func) llvm.Type {
 		return_type = llvm.StructType(elements, false)
 	}
 
+	// Func values are a pair of the function pointer and a context. If the
+	// context is non-nil, then the function is a closure, and the context
+	// is passed as an additional first argument.
 	fn_type := llvm.FunctionType(return_type, param_types, false)
-	return llvm.PointerType(fn_type, 0)
+	ctx_type := llvm.PointerType(llvm.Int8Type(), 0)
+	elements := []llvm.Type{llvm.PointerType(fn_type, 0), ctx_type}
+	return llvm.StructType(elements, false)
 }
 
 func (tm *TypeMap) interfaceLLVMType(i *types.Interface) llvm.Type {
+	// Interface values are a pair of the value pointer and, for empty
+	// interfaces, the runtime type; or for non-empty interfaces, the itab
+	// holding the runtime type and the methods' functions.
 	valptr_type := llvm.PointerType(llvm.Int8Type(), 0)
 	typptr_type := valptr_type // runtimeCommonType may not be defined yet
-	elements := make([]llvm.Type, 2+len(i.Methods))
-	elements[0] = valptr_type // value
-	elements[1] = typptr_type // type
-	for n, m := range i.Methods {
-		// Add an opaque pointer parameter to the function for the
-		// struct pointer.
-		fntype := m.Type.(*types.Func)
-		receiver_type := &types.Pointer{Base: types.Int8}
-		fntype.Recv = ast.NewObj(ast.Var, "")
-		fntype.Recv.Type = receiver_type
-		fnptr := types.Pointer{Base: fntype}
-		elements[n+2] = tm.ToLLVM(&fnptr)
-	}
+	elements := []llvm.Type{valptr_type, typptr_type}
 	return llvm.StructType(elements, false)
 }
 
 func (tm *TypeMap) mapLLVMType(m *types.Map) llvm.Type {
-	// XXX This map type will change in the future, when I get around to it.
-	// At the moment, it's representing a really dumb singly linked list.
-	list_type := llvm.GlobalContext().StructCreateNamed("")
-	list_ptr_type := llvm.PointerType(list_type, 0)
-	size_type := llvm.Int32Type()
-	element_types := []llvm.Type{size_type, list_type}
-	typ := llvm.StructType(element_types, false)
-	tm.types[m] = typ
-
-	list_element_types := []llvm.Type{
-		list_ptr_type, tm.ToLLVM(m.Key), tm.ToLLVM(m.Elt)}
-	list_type.StructSetBody(list_element_types, false)
-	return typ
+	// Maps are references to an opaque runtime structure, which is created
+	// by "make" or a composite literal and manipulated only by runtime
+	// functions.
+	return llvm.PointerType(llvm.Int8Type(), 0)
 }
 
 func (tm *TypeMap) chanLLVMType(c *types.Chan) llvm.Type {
-	panic("unimplemented")
+	// Channels are references to an opaque runtime structure, which is
+	// created by "make" and manipulated only by runtime functions.
+	return llvm.PointerType(llvm.Int8Type(), 0)
 }
 
 func (tm *TypeMap) nameLLVMType(n *types.Name) llvm.Type {
@@ -302,46 +316,86 @@ func (tm *TypeMap) nameLLVMType(n *types.Name) llvm.Type {
 
 ///////////////////////////////////////////////////////////////////////////////
 
+// makeRuntimeType creates the runtime type for a type. The global holding
+// the runtime type is recorded before the types it refers to are created,
+// as a type may refer to itself, e.g. through a pointer.
 func (tm *TypeMap) makeRuntimeType(t types.Type) llvm.Value {
-	switch t := t.(type) {
+	var lt llvm.Type
+	switch types.Underlying(t).(type) {
 	case *types.Bad:
-		return tm.badRuntimeType(t)
+		panic("bad type")
 	case *types.Basic:
-		return tm.basicRuntimeType(t)
+		lt = tm.runtimeCommonType
 	case *types.Array:
-		return tm.arrayRuntimeType(t)
+		lt = tm.runtimeArrayType
 	case *types.Slice:
-		return tm.sliceRuntimeType(t)
+		lt = tm.runtimeSliceType
 	case *types.Struct:
-		return tm.structRuntimeType(t)
+		lt = tm.runtimeStructType
 	case *types.Pointer:
-		return tm.pointerRuntimeType(t)
+		lt = tm.runtimePtrType
 	case *types.Func:
-		return tm.funcRuntimeType(t)
+		lt = tm.runtimeFuncType
 	case *types.Interface:
-		return tm.interfaceRuntimeType(t)
+		lt = tm.runtimeInterfaceType
 	case *types.Map:
-		return tm.mapRuntimeType(t)
+		lt = tm.runtimeMapType
 	case *types.Chan:
-		return tm.chanRuntimeType(t)
-	case *types.Name:
-		return tm.nameRuntimeType(t)
+		lt = tm.runtimeChanType
 	}
-	panic("unreachable")
+	// Unexported field and method names of unnamed types are assumed to
+	// be from the package being compiled.
+	result := llvm.AddGlobal(tm.module, lt, "")
+	pkgpath := tm.pkgname
+	if n, ok := t.(*types.Name); ok {
+		result.SetName("__llgo.reflect." + n.Obj.Name)
+		pkgpath = tm.pkgmap[n.Obj]
+	}
+	tm.runtime[tm.typeKey(t)] = result
+
+	commonType := tm.makeCommonType(t, result)
+	var init llvm.Value
+	switch u := types.Underlying(t).(type) {
+	case *types.Basic:
+		init = commonType
+	case *types.Array:
+		init = tm.arrayRuntimeType(u, commonType)
+	case *types.Slice:
+		init = tm.sliceRuntimeType(u, commonType)
+	case *types.Struct:
+		init = tm.structRuntimeType(u, commonType, pkgpath)
+	case *types.Pointer:
+		init = tm.pointerRuntimeType(u, commonType)
+	case *types.Func:
+		init = tm.funcRuntimeType(u, commonType)
+	case *types.Interface:
+		init = tm.interfaceRuntimeType(u, commonType, pkgpath)
+	case *types.Map:
+		init = tm.mapRuntimeType(u, commonType)
+	case *types.Chan:
+		init = tm.chanRuntimeType(u, commonType)
+	}
+	result.SetInitializer(init)
+	return result
 }
 
+// makeAlgorithmTable creates the algorithm table for a type. The runtime
+// calls the algorithms through func values, so each function is paired
+// with a nil context.
 func (tm *TypeMap) makeAlgorithmTable(t types.Type) llvm.Value {
-	// TODO set these to actual functions.
-	hashAlg := llvm.ConstNull(llvm.PointerType(tm.hashAlgFunctionType, 0))
-	equalAlg := llvm.ConstNull(llvm.PointerType(tm.equalAlgFunctionType, 0))
-	printAlg := llvm.ConstNull(llvm.PointerType(tm.printAlgFunctionType, 0))
-	copyAlg := llvm.ConstNull(llvm.PointerType(tm.copyAlgFunctionType, 0))
-
-	elems := []llvm.Value{hashAlg, equalAlg, printAlg, copyAlg}
+	algs := tm.algorithms(t)
+	fns := []llvm.Value{algs.hash, algs.equal, algs.print, algs.copy}
+	ctx := llvm.ConstNull(llvm.PointerType(llvm.Int8Type(), 0))
+	elems := make([]llvm.Value, len(fns))
+	for i, fn := range fns {
+		elems[i] = llvm.ConstStruct([]llvm.Value{fn, ctx}, false)
+	}
 	return llvm.ConstStruct(elems, false)
 }
 
-func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
+// makeCommonType creates the commonType header of the runtime type for t,
+// which is stored in the global result.
+func (tm *TypeMap) makeCommonType(t types.Type, result llvm.Value) llvm.Value {
 	// Not sure if there's an easier way to do this, but if you just
 	// use ConstStruct, you end up getting a different llvm.Type.
 	lt := tm.ToLLVM(t)
@@ -355,7 +409,13 @@ func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
 	}
 	typ = llvm.ConstInsertValue(typ, size, []uint32{0})
 
-	// TODO hash
+	// Hash of the type string.
+	str := tm.typeString(t)
+	h := fnv.New32a()
+	h.Write([]byte(str))
+	hash := llvm.ConstInt(elementTypes[1], uint64(h.Sum32()), false)
+	typ = llvm.ConstInsertValue(typ, hash, []uint32{1})
+
 	// TODO padding
 
 	// Alignment.
@@ -364,7 +424,7 @@ func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
 	typ = llvm.ConstInsertValue(typ, align, []uint32{4}) // field
 
 	// Kind.
-	kind := llvm.ConstInt(llvm.Int8Type(), uint64(k), false)
+	kind := llvm.ConstInt(llvm.Int8Type(), uint64(runtimeKind(t)), false)
 	typ = llvm.ConstInsertValue(typ, kind, []uint32{5})
 
 	// Algorithm table.
@@ -374,97 +434,446 @@ func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
 	algptr = llvm.ConstBitCast(algptr, elementTypes[6])
 	typ = llvm.ConstInsertValue(typ, algptr, []uint32{6})
 
-	// TODO string
+	// String.
+	typ = llvm.ConstInsertValue(typ, tm.globalString(str), []uint32{7})
+
+	// Name and methods of named types, and pointers to named types.
+	var uncommonType llvm.Value
+	switch t := t.(type) {
+	case *types.Name:
+		uncommonType = tm.uncommonType(t, false)
+	case *types.Pointer:
+		if n, ok := t.Base.(*types.Name); ok {
+			uncommonType = tm.uncommonType(n, true)
+		}
+	}
+	if !uncommonType.IsNil() {
+		typ = llvm.ConstInsertValue(typ, uncommonType, []uint32{8})
+	}
+
+	ptr := llvm.ConstBitCast(result, elementTypes[9])
+	typ = llvm.ConstInsertValue(typ, ptr, []uint32{9})
 	return typ
 }
 
-func (tm *TypeMap) badRuntimeType(b *types.Bad) llvm.Value {
-	panic("bad type")
+// runtimeKind returns the reflect.Kind of a type.
+func runtimeKind(t types.Type) reflect.Kind {
+	switch t := types.Underlying(t).(type) {
+	case *types.Basic:
+		return reflect.Kind(t.Kind)
+	case *types.Array:
+		return reflect.Array
+	case *types.Slice:
+		return reflect.Slice
+	case *types.Struct:
+		return reflect.Struct
+	case *types.Pointer:
+		return reflect.Ptr
+	case *types.Func:
+		return reflect.Func
+	case *types.Interface:
+		return reflect.Interface
+	case *types.Map:
+		return reflect.Map
+	case *types.Chan:
+		return reflect.Chan
+	}
+	panic("unreachable")
 }
 
-func (tm *TypeMap) basicRuntimeType(b *types.Basic) llvm.Value {
-	commonType := tm.makeCommonType(b, reflect.Kind(b.Kind))
-	result := llvm.AddGlobal(tm.module, commonType.Type(), "")
-	elementTypes := tm.runtimeCommonType.StructElementTypes()
-	ptr := llvm.ConstBitCast(result, elementTypes[9])
-	commonType = llvm.ConstInsertValue(commonType, ptr, []uint32{9})
-	result.SetInitializer(commonType)
-	return result
+// runtimeTypePointer returns a pointer to the runtime type for t, of the
+// specified pointer type.
+func (tm *TypeMap) runtimeTypePointer(t types.Type, ptrType llvm.Type) llvm.Value {
+	return llvm.ConstBitCast(tm.ToRuntime(t), ptrType)
 }
 
-func (tm *TypeMap) arrayRuntimeType(a *types.Array) llvm.Value {
-	panic("unimplemented")
+// runtimeTypeSlice creates a constant slice of pointers to the runtime
+// types for the types of the specified objects, of the specified slice
+// type.
+func (tm *TypeMap) runtimeTypeSlice(objs []*ast.Object, slicetyp llvm.Type) llvm.Value {
+	ptrType := slicetyp.StructElementTypes()[0].ElementType()
+	values := make([]llvm.Value, len(objs))
+	for i, obj := range objs {
+		values[i] = tm.runtimeTypePointer(obj.Type.(types.Type), ptrType)
+	}
+	return tm.makeSlice(values, slicetyp)
 }
 
-func (tm *TypeMap) sliceRuntimeType(s *types.Slice) llvm.Value {
-	panic("unimplemented")
+// pkgPathString returns the package path recorded in the runtime type
+// for a field or method name, which is nil for exported names.
+func (tm *TypeMap) pkgPathString(name, pkgpath string, ptrType llvm.Type) llvm.Value {
+	if pkgpath == "" || ast.IsExported(name) {
+		return llvm.ConstNull(ptrType)
+	}
+	return tm.globalString(pkgpath)
 }
 
-func (tm *TypeMap) structRuntimeType(s *types.Struct) llvm.Value {
-	result := llvm.AddGlobal(tm.module, tm.runtimeStructType, "")
-	elementTypes := tm.runtimeCommonType.StructElementTypes()
-	ptr := llvm.ConstBitCast(result, elementTypes[9])
-	commonType := tm.makeCommonType(s, reflect.Struct)
-	commonType = llvm.ConstInsertValue(commonType, ptr, []uint32{9})
+func (tm *TypeMap) arrayRuntimeType(a *types.Array, commonType llvm.Value) llvm.Value {
+	elementTypes := tm.runtimeArrayType.StructElementTypes()
+	init := llvm.ConstNull(tm.runtimeArrayType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
+	elem := tm.runtimeTypePointer(a.Elt, elementTypes[1])
+	init = llvm.ConstInsertValue(init, elem, []uint32{1})
+	slice := tm.runtimeTypePointer(&types.Slice{Elt: a.Elt}, elementTypes[2])
+	init = llvm.ConstInsertValue(init, slice, []uint32{2})
+	len_ := llvm.ConstInt(elementTypes[3], a.Len, false)
+	init = llvm.ConstInsertValue(init, len_, []uint32{3})
+	return init
+}
 
-	init := llvm.ConstNull(tm.runtimeStructType)
+func (tm *TypeMap) sliceRuntimeType(s *types.Slice, commonType llvm.Value) llvm.Value {
+	elementTypes := tm.runtimeSliceType.StructElementTypes()
+	init := llvm.ConstNull(tm.runtimeSliceType)
 	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
-	result.SetInitializer(init)
+	elem := tm.runtimeTypePointer(s.Elt, elementTypes[1])
+	init = llvm.ConstInsertValue(init, elem, []uint32{1})
+	return init
+}
 
-	// TODO set fields
+// structRuntimeType creates the runtime type for a struct, recording the
+// name, type, tag and offset of each field. Embedded fields have no name.
+func (tm *TypeMap) structRuntimeType(s *types.Struct, commonType llvm.Value, pkgpath string) llvm.Value {
+	init := llvm.ConstNull(tm.runtimeStructType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
 
-	//panic("unimplemented")
-	return result
+	fieldsSliceType := tm.runtimeStructType.StructElementTypes()[1]
+	fieldType := fieldsSliceType.StructElementTypes()[0].ElementType()
+	elementTypes := fieldType.StructElementTypes()
+	lt := tm.ToLLVM(s)
+	fields := make([]llvm.Value, len(s.Fields))
+	for i, f := range s.Fields {
+		field := llvm.ConstNull(fieldType)
+		if f.Name != "" {
+			name := tm.globalString(f.Name)
+			field = llvm.ConstInsertValue(field, name, []uint32{0})
+			pkgPath := tm.pkgPathString(f.Name, pkgpath, elementTypes[1])
+			field = llvm.ConstInsertValue(field, pkgPath, []uint32{1})
+		}
+		typ := tm.runtimeTypePointer(f.Type.(types.Type), elementTypes[2])
+		field = llvm.ConstInsertValue(field, typ, []uint32{2})
+		if i < len(s.Tags) && s.Tags[i] != "" {
+			tag := tm.globalString(s.Tags[i])
+			field = llvm.ConstInsertValue(field, tag, []uint32{3})
+		}
+		offset := llvm.ConstInt(elementTypes[4], tm.target.ElementOffset(lt, i), false)
+		field = llvm.ConstInsertValue(field, offset, []uint32{4})
+		fields[i] = field
+	}
+	init = llvm.ConstInsertValue(init, tm.makeSlice(fields, fieldsSliceType), []uint32{1})
+	return init
 }
 
-func (tm *TypeMap) pointerRuntimeType(p *types.Pointer) llvm.Value {
-	panic("unimplemented")
+func (tm *TypeMap) pointerRuntimeType(p *types.Pointer, commonType llvm.Value) llvm.Value {
+	init := llvm.ConstNull(tm.runtimePtrType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
+	elemType := tm.runtimePtrType.StructElementTypes()[1]
+	elem := tm.runtimeTypePointer(p.Base, elemType)
+	init = llvm.ConstInsertValue(init, elem, []uint32{1})
+	return init
 }
 
-func (tm *TypeMap) funcRuntimeType(f *types.Func) llvm.Value {
-	panic("unimplemented")
+// funcRuntimeType creates the runtime type for a function type. The
+// receiver of a method, if any, is recorded as the first parameter.
+func (tm *TypeMap) funcRuntimeType(f *types.Func, commonType llvm.Value) llvm.Value {
+	elementTypes := tm.runtimeFuncType.StructElementTypes()
+	init := llvm.ConstNull(tm.runtimeFuncType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
+	if f.IsVariadic {
+		dotdotdot := llvm.ConstAllOnes(elementTypes[1])
+		init = llvm.ConstInsertValue(init, dotdotdot, []uint32{1})
+	}
+	params := f.Params
+	if f.Recv != nil {
+		params = append([]*ast.Object{f.Recv}, params...)
+	}
+	in := tm.runtimeTypeSlice(params, elementTypes[2])
+	init = llvm.ConstInsertValue(init, in, []uint32{2})
+	out := tm.runtimeTypeSlice(f.Results, elementTypes[3])
+	init = llvm.ConstInsertValue(init, out, []uint32{3})
+	return init
 }
 
-func (tm *TypeMap) interfaceRuntimeType(i *types.Interface) llvm.Value {
-	panic("unimplemented")
-}
+func (tm *TypeMap) interfaceRuntimeType(i *types.Interface, commonType llvm.Value, pkgpath string) llvm.Value {
+	init := llvm.ConstNull(tm.runtimeInterfaceType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
 
-func (tm *TypeMap) mapRuntimeType(m *types.Map) llvm.Value {
-	result := llvm.AddGlobal(tm.module, tm.runtimeMapType, "")
-	elementTypes := tm.runtimeCommonType.StructElementTypes()
-	ptr := llvm.ConstBitCast(result, elementTypes[9])
-	commonType := tm.makeCommonType(m, reflect.Map)
-	commonType = llvm.ConstInsertValue(commonType, ptr, []uint32{9})
+	// Methods, sorted by name.
+	methodsSliceType := tm.runtimeInterfaceType.StructElementTypes()[1]
+	imethodType := methodsSliceType.StructElementTypes()[0].ElementType()
+	elementTypes := imethodType.StructElementTypes()
+	imethods := make([]llvm.Value, len(i.Methods))
+	for j, m := range i.Methods {
+		imethod := llvm.ConstNull(imethodType)
+		name := tm.globalString(m.Name)
+		imethod = llvm.ConstInsertValue(imethod, name, []uint32{0})
+		pkgPath := tm.pkgPathString(m.Name, pkgpath, elementTypes[1])
+		imethod = llvm.ConstInsertValue(imethod, pkgPath, []uint32{1})
+		typ := tm.runtimeTypePointer(types.MethodFuncType(m.Type.(*types.Func), nil), elementTypes[2])
+		imethod = llvm.ConstInsertValue(imethod, typ, []uint32{2})
+		imethods[j] = imethod
+	}
+	methods := tm.makeSlice(imethods, methodsSliceType)
+	init = llvm.ConstInsertValue(init, methods, []uint32{1})
+	return init
+}
 
+func (tm *TypeMap) mapRuntimeType(m *types.Map, commonType llvm.Value) llvm.Value {
+	elementTypes := tm.runtimeMapType.StructElementTypes()
 	init := llvm.ConstNull(tm.runtimeMapType)
 	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
-	result.SetInitializer(init)
+	key := tm.runtimeTypePointer(m.Key, elementTypes[1])
+	init = llvm.ConstInsertValue(init, key, []uint32{1})
+	elem := tm.runtimeTypePointer(m.Elt, elementTypes[2])
+	init = llvm.ConstInsertValue(init, elem, []uint32{2})
+	return init
+}
 
-	// TODO set key, elem
+func (tm *TypeMap) chanRuntimeType(c *types.Chan, commonType llvm.Value) llvm.Value {
+	elementTypes := tm.runtimeChanType.StructElementTypes()
+	init := llvm.ConstNull(tm.runtimeChanType)
+	init = llvm.ConstInsertValue(init, commonType, []uint32{0})
+	elem := tm.runtimeTypePointer(c.Elt, elementTypes[1])
+	init = llvm.ConstInsertValue(init, elem, []uint32{1})
+
+	// Direction. The AST and reflect directions are mirror images.
+	var dir reflect.ChanDir
+	switch c.Dir {
+	case ast.SEND:
+		dir = reflect.SendDir
+	case ast.RECV:
+		dir = reflect.RecvDir
+	default:
+		dir = reflect.BothDir
+	}
+	dirValue := llvm.ConstInt(elementTypes[2], uint64(dir), false)
+	init = llvm.ConstInsertValue(init, dirValue, []uint32{2})
+	return init
+}
 
-	return result
+// uncommonType creates the uncommonType structure for a named type, or for
+// a pointer to a named type, which records the method set of the type, and
+// the name and package of named types.
+func (tm *TypeMap) uncommonType(n *types.Name, ptr bool) llvm.Value {
+	init := llvm.ConstNull(tm.runtimeUncommonType)
+	pkgpath := tm.pkgmap[n.Obj]
+	if !ptr {
+		name := tm.globalString(n.Obj.Name)
+		init = llvm.ConstInsertValue(init, name, []uint32{0})
+		if pkgpath != "" {
+			init = llvm.ConstInsertValue(init, tm.globalString(pkgpath), []uint32{1})
+		}
+	}
+
+	// Methods, sorted by name. The method set of a named type contains
+	// only the methods with value receivers, whereas the method set of a
+	// pointer type contains all of the methods.
+	var recvtype types.Type = n
+	if ptr {
+		recvtype = &types.Pointer{Base: n}
+	}
+	methodsSliceType := tm.runtimeUncommonType.StructElementTypes()[2]
+	methodType := methodsSliceType.StructElementTypes()[0].ElementType()
+	elementTypes := methodType.StructElementTypes()
+	fnptrType := elementTypes[4]
+	var methods []llvm.Value
+	for _, m := range n.Methods {
+		recv := m.Type.(*types.Func).Recv.Type.(types.Type)
+		if _, isptr := recv.(*types.Pointer); isptr && !ptr {
+			continue
+		}
+		fn := tm.resolver.Resolve(m).LLVMValue()
+		fn = llvm.ConstExtractValue(fn, []uint32{0})
+		tfn := llvm.ConstPtrToInt(fn, fnptrType)
+		ifn := llvm.ConstPtrToInt(tm.resolver.InterfaceMethod(m, ptr), fnptrType)
+		method := llvm.ConstNull(methodType)
+		method = llvm.ConstInsertValue(method, tm.globalString(m.Name), []uint32{0})
+		pkgPath := tm.pkgPathString(m.Name, pkgpath, elementTypes[1])
+		method = llvm.ConstInsertValue(method, pkgPath, []uint32{1})
+		mtyp := tm.runtimeTypePointer(types.MethodFuncType(m.Type.(*types.Func), nil), elementTypes[2])
+		method = llvm.ConstInsertValue(method, mtyp, []uint32{2})
+		typ := tm.runtimeTypePointer(types.MethodFuncType(m.Type.(*types.Func), recvtype), elementTypes[3])
+		method = llvm.ConstInsertValue(method, typ, []uint32{3})
+		method = llvm.ConstInsertValue(method, ifn, []uint32{4})
+		method = llvm.ConstInsertValue(method, tfn, []uint32{5})
+		methods = append(methods, method)
+	}
+	init = llvm.ConstInsertValue(init, tm.makeSlice(methods, methodsSliceType),
+		[]uint32{2})
+
+	uncommonType := llvm.AddGlobal(tm.module, init.Type(), "")
+	uncommonType.SetInitializer(init)
+	return uncommonType
 }
 
-func (tm *TypeMap) chanRuntimeType(c *types.Chan) llvm.Value {
-	panic("unimplemented")
+// typeString returns the string representation of a type, as recorded in
+// its runtime type. Named types are qualified by their package name.
+func (tm *TypeMap) typeString(t types.Type) string {
+	return tm.formatType(t, false)
 }
 
-func (tm *TypeMap) nameRuntimeType(n *types.Name) llvm.Value {
-	underlyingRuntimeType := tm.ToRuntime(n.Underlying).Initializer()
-	result := llvm.AddGlobal(tm.module, underlyingRuntimeType.Type(), "")
-	result.SetName("__llgo.reflect." + n.Obj.Name)
-	elementTypes := tm.runtimeCommonType.StructElementTypes()
-	ptr := llvm.ConstBitCast(result, elementTypes[9])
-	commonType := llvm.ConstInsertValue(underlyingRuntimeType, ptr, []uint32{9})
+// typeKey returns a string identifying a type, such that identical types
+// have the same key. The key is the type's string representation, with
+// each named type further qualified by the number of its declaration, as
+// distinct declarations (e.g. in different functions) may share a name.
+func (tm *TypeMap) typeKey(t types.Type) string {
+	return tm.formatType(t, true)
+}
 
-	uncommonTypeInit := llvm.ConstNull(tm.runtimeUncommonType) // TODO
-	uncommonType := llvm.AddGlobal(tm.module, uncommonTypeInit.Type(), "")
-	uncommonType.SetInitializer(uncommonTypeInit)
-	commonType = llvm.ConstInsertValue(commonType, uncommonType, []uint32{8})
+// formatType returns the string representation of a type, as for
+// typeString, or if key is true, the key of the type, as for typeKey.
+func (tm *TypeMap) formatType(t types.Type, key bool) string {
+	switch t := t.(type) {
+	case *types.Basic:
+		return t.Kind.String()
+	case *types.Array:
+		return "[" + strconv.FormatUint(t.Len, 10) + "]" + tm.formatType(t.Elt, key)
+	case *types.Slice:
+		return "[]" + tm.formatType(t.Elt, key)
+	case *types.Struct:
+		if len(t.Fields) == 0 {
+			return "struct {}"
+		}
+		s := "struct {"
+		for i, f := range t.Fields {
+			if i > 0 {
+				s += ";"
+			}
+			s += " "
+			if f.Name != "" {
+				s += f.Name + " "
+			}
+			s += tm.formatType(f.Type.(types.Type), key)
+			if i < len(t.Tags) && t.Tags[i] != "" {
+				s += " " + strconv.Quote(t.Tags[i])
+			}
+		}
+		return s + " }"
+	case *types.Pointer:
+		return "*" + tm.formatType(t.Base, key)
+	case *types.Func:
+		return "func" + tm.signatureString(t, key)
+	case *types.Interface:
+		if len(t.Methods) == 0 {
+			return "interface {}"
+		}
+		s := "interface {"
+		for i, m := range t.Methods {
+			if i > 0 {
+				s += ";"
+			}
+			s += " " + m.Name + tm.signatureString(m.Type.(*types.Func), key)
+		}
+		return s + " }"
+	case *types.Map:
+		return "map[" + tm.formatType(t.Key, key) + "]" + tm.formatType(t.Elt, key)
+	case *types.Chan:
+		elem := tm.formatType(t.Elt, key)
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + elem
+		case ast.RECV:
+			return "<-chan " + elem
+		}
+		// Parenthesize a receive-only element type, which would
+		// otherwise be taken as part of the channel's direction.
+		if c, ok := t.Elt.(*types.Chan); ok && c.Dir == ast.RECV {
+			elem = "(" + elem + ")"
+		}
+		return "chan " + elem
+	case *types.Name:
+		s := t.Obj.Name
+		if pkg := tm.pkgmap[t.Obj]; pkg != "" {
+			s = pkg + "." + s
+		}
+		if key {
+			n, ok := tm.names[t.Obj]
+			if !ok {
+				n = len(tm.names)
+				tm.names[t.Obj] = n
+			}
+			s += "#" + strconv.Itoa(n)
+		}
+		return s
+	}
+	panic("unreachable")
+}
+
+// signatureString returns the string representation of the parameters and
+// results of a function type, excluding its receiver. If key is true, the
+// types are formatted as for typeKey.
+func (tm *TypeMap) signatureString(f *types.Func, key bool) string {
+	s := "("
+	for i, p := range f.Params {
+		if i > 0 {
+			s += ", "
+		}
+		ptyp := p.Type.(types.Type)
+		if f.IsVariadic && i == len(f.Params)-1 {
+			s += "..." + tm.formatType(ptyp.(*types.Slice).Elt, key)
+		} else {
+			s += tm.formatType(ptyp, key)
+		}
+	}
+	s += ")"
+	switch len(f.Results) {
+	case 0:
+	case 1:
+		s += " " + tm.formatType(f.Results[0].Type.(types.Type), key)
+	default:
+		s += " ("
+		for i, r := range f.Results {
+			if i > 0 {
+				s += ", "
+			}
+			s += tm.formatType(r.Type.(types.Type), key)
+		}
+		s += ")"
+	}
+	return s
+}
 
-	// TODO set string, uncommonType.
-	result.SetInitializer(commonType)
+// globalString creates a constant global string, returning a pointer to
+// it, for the names recorded in runtime types.
+func (tm *TypeMap) globalString(s string) llvm.Value {
+	data := llvm.ConstString(s, false)
+	dataptr := llvm.AddGlobal(tm.module, data.Type(), "")
+	dataptr.SetInitializer(data)
+	dataptr.SetGlobalConstant(true)
+	dataptr.SetLinkage(llvm.InternalLinkage)
+
+	stringType := tm.ToLLVM(types.String)
+	elementTypes := stringType.StructElementTypes()
+	init := llvm.ConstNull(stringType)
+	init = llvm.ConstInsertValue(init,
+		llvm.ConstBitCast(dataptr, elementTypes[0]), []uint32{0})
+	init = llvm.ConstInsertValue(init,
+		llvm.ConstInt(elementTypes[1], uint64(len(s)), false), []uint32{1})
+	result := llvm.AddGlobal(tm.module, stringType, "")
+	result.SetInitializer(init)
+	result.SetGlobalConstant(true)
+	result.SetLinkage(llvm.InternalLinkage)
 	return result
 }
 
+// makeSlice creates a constant slice of the specified slice type, whose
+// elements are stored in a new global array.
+func (tm *TypeMap) makeSlice(values []llvm.Value, slicetyp llvm.Type) llvm.Value {
+	ptrtyp := slicetyp.StructElementTypes()[0]
+	var globalptr llvm.Value
+	if len(values) > 0 {
+		array := llvm.ConstArray(ptrtyp.ElementType(), values)
+		globalptr = llvm.AddGlobal(tm.module, array.Type(), "")
+		globalptr.SetInitializer(array)
+		globalptr = llvm.ConstBitCast(globalptr, ptrtyp)
+	} else {
+		globalptr = llvm.ConstNull(ptrtyp)
+	}
+	len_ := llvm.ConstInt(llvm.Int32Type(), uint64(len(values)), false)
+	slice := llvm.ConstNull(slicetyp)
+	slice = llvm.ConstInsertValue(slice, globalptr, []uint32{0})
+	slice = llvm.ConstInsertValue(slice, len_, []uint32{1})
+	slice = llvm.ConstInsertValue(slice, len_, []uint32{2})
+	return slice
+}
+
 // vim: set ft=go :
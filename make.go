@@ -23,16 +23,35 @@ SOFTWARE.
 package llgo
 
 import (
-	"github.com/axw/gollvm/llvm"
-	//"github.com/axw/llgo/types"
+	"fmt"
+	"github.com/axw/llgo/types"
 	"go/ast"
 )
 
 func (c *compiler) VisitMake(expr *ast.CallExpr) Value {
 	typ := c.GetType(expr.Args[0])
-	// TODO len (if slice)
-	// TODO cap
-	return c.NewLLVMValue(llvm.ConstNull(c.types.ToLLVM(typ)), typ)
+	switch types.Underlying(typ).(type) {
+	case *types.Chan:
+		var capacity Value
+		if len(expr.Args) > 1 {
+			capacity = c.VisitExpr(expr.Args[1])
+		}
+		return c.makeChan(typ, capacity)
+	case *types.Map:
+		var hint Value
+		if len(expr.Args) > 1 {
+			hint = c.VisitExpr(expr.Args[1])
+		}
+		return c.makeMap(typ, hint)
+	case *types.Slice:
+		length := c.VisitExpr(expr.Args[1])
+		var capacity Value
+		if len(expr.Args) > 2 {
+			capacity = c.VisitExpr(expr.Args[2])
+		}
+		return c.makeSlice(typ, length, capacity)
+	}
+	panic(fmt.Sprint("Unhandled type in make: ", typ))
 }
 
 // vim: set ft=go :
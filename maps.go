@@ -25,40 +25,126 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
+	"go/ast"
 )
 
-// mapInsert inserts a key into a map, returning a pointer to the memory
-// location for the value.
-func (c *compiler) mapInsert(m *LLVMValue, key Value) *LLVMValue {
-	mapType := m.Type().(*types.Map)
-	mapinsert := c.module.Module.NamedFunction("runtime.mapinsert")
+// makeMap creates a new map of the specified type, with space for at least
+// hint entries. If hint is nil, the map is created with the default size.
+func (c *compiler) makeMap(typ types.Type, hint Value) *LLVMValue {
+	maptyp := types.Underlying(typ).(*types.Map)
 	ptrType := c.target.IntPtrType()
-	if mapinsert.IsNil() {
-		// params: dynamic type, mapptr, keyptr
-		paramTypes := []llvm.Type{ptrType, ptrType, ptrType}
-		funcType := llvm.FunctionType(ptrType, paramTypes, false)
-		mapinsert = llvm.AddFunction(c.module.Module, "runtime.mapinsert", funcType)
-	}
-	args := make([]llvm.Value, 3)
-	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(m.Type()), ptrType)
-	args[1] = c.builder.CreatePtrToInt(m.pointer.LLVMValue(), ptrType, "")
+	paramTypes := []llvm.Type{ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	mapmake := c.namedFunction("runtime.mapmake", funcType)
 
-	if lv, islv := key.(*LLVMValue); islv && lv.pointer != nil {
-		args[2] = c.builder.CreatePtrToInt(lv.pointer.LLVMValue(), ptrType, "")
+	keytype := llvm.ConstPtrToInt(c.types.ToRuntime(maptyp.Key), ptrType)
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(maptyp.Elt))
+	args := []llvm.Value{
+		keytype,
+		llvm.ConstInt(ptrType, elemsize, false),
+		llvm.ConstNull(ptrType)}
+	if hint != nil {
+		args[2] = hint.Convert(types.Int).LLVMValue()
 	}
-	if args[2].IsNil() {
-		// Create global constant, so we can take its address.
-		global := llvm.AddGlobal(c.module.Module, c.types.ToLLVM(key.Type()), "")
-		global.SetGlobalConstant(true)
-		global.SetInitializer(key.LLVMValue())
-		args[2] = c.builder.CreatePtrToInt(global, ptrType, "")
+	m := c.builder.CreateCall(mapmake, args, "")
+	m = c.builder.CreateIntToPtr(m, c.types.ToLLVM(typ), "")
+	return c.NewLLVMValue(m, typ)
+}
+
+// mapKeyPointer stores a map key in a stack slot in the entry block, and
+// returns its address as an integer, for passing to the runtime.
+func (c *compiler) mapKeyPointer(m *LLVMValue, key Value) llvm.Value {
+	keytyp := types.Underlying(m.Type()).(*types.Map).Key
+	keyptr := c.entryAlloca(c.types.ToLLVM(keytyp), "")
+	c.builder.CreateStore(key.Convert(keytyp).LLVMValue(), keyptr)
+	return c.builder.CreatePtrToInt(keyptr, c.target.IntPtrType(), "")
+}
+
+// mapInsert inserts a key into a map, if it is not already present, and
+// returns the element for the key. The resulting value is addressable, so
+// that it may be assigned to.
+func (c *compiler) mapInsert(m *LLVMValue, key Value) *LLVMValue {
+	elttyp := types.Underlying(m.Type()).(*types.Map).Elt
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	mapassign := c.namedFunction("runtime.mapassign", funcType)
+
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, ""),
+		c.mapKeyPointer(m, key)}
+	eltptrtyp := &types.Pointer{Base: elttyp}
+	eltptr := c.createCall(mapassign, args)
+	eltptr = c.builder.CreateIntToPtr(eltptr, c.types.ToLLVM(eltptrtyp), "")
+	return c.NewLLVMValue(eltptr, eltptrtyp).makePointee()
+}
+
+// mapLookup looks up a key in a map, without inserting it. If commaOk is
+// true, then the result is a {value, ok} pair, where ok is false if the key
+// is not present in the map. If the key is not present, then the value is
+// the zero value of the map's element type.
+func (c *compiler) mapLookup(m *LLVMValue, key Value, commaOk bool) *LLVMValue {
+	elttyp := types.Underlying(m.Type()).(*types.Map).Elt
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	mapaccess := c.namedFunction("runtime.mapaccess", funcType)
+
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, ""),
+		c.mapKeyPointer(m, key)}
+	eltptr := c.createCall(mapaccess, args)
+
+	// If the key is present, load the element from the pointer returned
+	// by mapaccess.
+	eltType := c.types.ToLLVM(elttyp)
+	result := c.entryAlloca(eltType, "")
+	c.builder.CreateStore(llvm.ConstNull(eltType), result)
+	ok := c.builder.CreateIsNotNull(eltptr, "")
+	currBlock := c.builder.GetInsertBlock()
+	end := llvm.AddBasicBlock(currBlock.Parent(), "end")
+	end.MoveAfter(currBlock)
+	present := llvm.InsertBasicBlock(end, "present")
+	c.builder.CreateCondBr(ok, present, end)
+	c.builder.SetInsertPointAtEnd(present)
+	eltptr = c.builder.CreateIntToPtr(eltptr, llvm.PointerType(eltType, 0), "")
+	c.builder.CreateStore(c.builder.CreateLoad(eltptr, ""), result)
+	c.builder.CreateBr(end)
+	c.builder.SetInsertPointAtEnd(end)
+
+	value := c.NewLLVMValue(c.builder.CreateLoad(result, ""), elttyp)
+	if !commaOk {
+		return value
 	}
+	return c.commaOkValue(value, ok)
+}
 
-	eltPtrType := &types.Pointer{Base: mapType.Elt}
-	result := c.builder.CreateCall(mapinsert, args, "")
-	result = c.builder.CreateIntToPtr(result, c.types.ToLLVM(eltPtrType), "")
-	value := c.NewLLVMValue(result, eltPtrType)
-	return value.makePointee()
+// mapLen returns the number of entries in a map.
+func (c *compiler) mapLen(m *LLVMValue) *LLVMValue {
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	maplen := c.namedFunction("runtime.maplen", funcType)
+	args := []llvm.Value{c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, "")}
+	result := c.builder.CreateCall(maplen, args, "")
+	return c.NewLLVMValue(result, types.Int)
+}
+
+func (c *compiler) VisitDelete(expr *ast.CallExpr) Value {
+	if len(expr.Args) != 2 {
+		panic("Expecting exactly two arguments to delete")
+	}
+	m := c.VisitExpr(expr.Args[0]).(*LLVMValue)
+	key := c.VisitExpr(expr.Args[1])
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.VoidType(), paramTypes, false)
+	mapdelete := c.namedFunction("runtime.mapdelete", funcType)
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, ""),
+		c.mapKeyPointer(m, key)}
+	c.createCall(mapdelete, args)
+	return nil
 }
 
 // vim: set ft=go:
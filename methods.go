@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// bindMethod binds a method to its receiver, producing a func value. The
+// func value is a closure whose context holds the method's function pointer
+// and a copy of the receiver, which a thunk loads to call the method.
+func (c *compiler) bindMethod(method *LLVMValue) *LLVMValue {
+	methodType := method.Type().(*types.Func)
+	fnType := &types.Func{
+		Params:     methodType.Params,
+		Results:    methodType.Results,
+		IsVariadic: methodType.IsVariadic,
+	}
+	fn_pair_type := c.types.ToLLVM(fnType)
+	element_types := fn_pair_type.StructElementTypes()
+
+	// Store the method and receiver in the closure context.
+	fnptr := c.builder.CreateExtractValue(method.LLVMValue(), 0, "")
+	receiver := method.receiver.LLVMValue()
+	ctxType := llvm.StructType([]llvm.Type{fnptr.Type(), receiver.Type()}, false)
+	ctx := c.builder.CreateMalloc(ctxType, "")
+	c.builder.CreateStore(fnptr, c.builder.CreateStructGEP(ctx, 0, ""))
+	c.builder.CreateStore(receiver, c.builder.CreateStructGEP(ctx, 1, ""))
+
+	// The thunk takes the context as an additional first argument.
+	llvmFnType := element_types[0].ElementType()
+	paramTypes := append([]llvm.Type{element_types[1]}, llvmFnType.ParamTypes()...)
+	thunkType := llvm.FunctionType(llvmFnType.ReturnType(), paramTypes, false)
+	thunk := llvm.AddFunction(c.module.Module, "", thunkType)
+	fn_value := llvm.ConstNull(fn_pair_type)
+	fn_value = llvm.ConstInsertValue(fn_value,
+		llvm.ConstBitCast(thunk, element_types[0]), []uint32{0})
+	fn_value = c.builder.CreateInsertValue(fn_value,
+		c.builder.CreateBitCast(ctx, element_types[1], ""), 1, "")
+
+	// When done, return to where we were.
+	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+
+	// Generate the thunk, which loads the method and receiver from the
+	// context and calls the method with the remaining arguments.
+	entry := llvm.AddBasicBlock(thunk, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	ctx = c.builder.CreateBitCast(thunk.Param(0), llvm.PointerType(ctxType, 0), "")
+	fnptr = c.builder.CreateLoad(c.builder.CreateStructGEP(ctx, 0, ""), "")
+	args := []llvm.Value{c.builder.CreateLoad(c.builder.CreateStructGEP(ctx, 1, ""), "")}
+	for i := 1; i < len(paramTypes); i++ {
+		args = append(args, thunk.Param(i))
+	}
+	result := c.builder.CreateCall(fnptr, args, "")
+	if len(fnType.Results) == 0 {
+		c.builder.CreateRetVoid()
+	} else {
+		c.builder.CreateRet(result)
+	}
+	return c.NewLLVMValue(fn_value, fnType)
+}
+
+// methodExpr compiles the method expression T.M, where typ is T and sel is
+// M. The result is a func value which takes the receiver as its first
+// parameter.
+func (c *compiler) methodExpr(typ types.Type, sel *ast.Ident) *LLVMValue {
+	methodType := c.ObjGetType(sel.Obj).(*types.Func)
+	recv := ast.NewObj(ast.Var, "")
+	recv.Type = typ
+	fnType := &types.Func{
+		Params:     append([]*ast.Object{recv}, methodType.Params...),
+		Results:    methodType.Results,
+		IsVariadic: methodType.IsVariadic,
+	}
+	fn_pair_type := c.types.ToLLVM(fnType)
+	element_types := fn_pair_type.StructElementTypes()
+
+	// If the method's receiver has type T, then the method function
+	// already takes the receiver as its first parameter.
+	if methodType.Recv != nil {
+		if types.Identical(methodType.Recv.Type.(types.Type), typ) {
+			method := c.Resolve(sel.Obj).(*LLVMValue)
+			fnptr := llvm.ConstExtractValue(method.LLVMValue(), []uint32{0})
+			fnptr = llvm.ConstBitCast(fnptr, element_types[0])
+			fn_value := llvm.ConstInsertValue(
+				llvm.ConstNull(fn_pair_type), fnptr, []uint32{0})
+			return c.NewLLVMValue(fn_value, fnType)
+		}
+	}
+
+	// Otherwise generate a function which selects the method from the
+	// receiver, and calls it. This handles promoted methods, methods with
+	// value receivers selected through a pointer type, and interface
+	// methods.
+	llvmFnType := element_types[0].ElementType()
+	fn := llvm.AddFunction(c.module.Module, "", llvmFnType)
+	fn_value := llvm.ConstInsertValue(
+		llvm.ConstNull(fn_pair_type), fn, []uint32{0})
+
+	// When done, return to where we were.
+	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+
+	entry := llvm.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.pushFunction(c.NewLLVMValue(fn, fnType), nil)
+
+	// Store the receiver on the stack, so that it is addressable.
+	receiverType := c.types.ToLLVM(typ)
+	ptr := c.builder.CreateAlloca(receiverType, "")
+	c.builder.CreateStore(fn.Param(0), ptr)
+	receiver := c.NewLLVMValue(ptr, &types.Pointer{Base: typ}).makePointee()
+
+	method := c.selector(receiver, sel).(*LLVMValue)
+	args := []llvm.Value{method.receiver.LLVMValue()}
+	for i := 1; i < len(llvmFnType.ParamTypes()); i++ {
+		args = append(args, fn.Param(i))
+	}
+	result := c.callFunc(method, args)
+	if len(fnType.Results) == 0 {
+		c.builder.CreateRetVoid()
+	} else {
+		c.builder.CreateRet(result)
+	}
+	c.functions = c.functions[0 : len(c.functions)-1]
+	return c.NewLLVMValue(fn_value, fnType)
+}
+
+// vim: set ft=go :
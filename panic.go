@@ -0,0 +1,171 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// createCall creates a call to fn. If the current function has deferred
+// calls, then the call is made with an invoke instruction, so that the
+// deferred calls are run by the function's landing pad if the callee
+// panics.
+func (c *compiler) createCall(fn llvm.Value, args []llvm.Value) llvm.Value {
+	if len(c.functions) > 0 {
+		f := c.functions[len(c.functions)-1]
+		if !f.unwindblock.IsNil() {
+			currBlock := c.builder.GetInsertBlock()
+			contBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+			contBlock.MoveAfter(currBlock)
+			result := c.builder.CreateInvoke(fn, args, contBlock, f.unwindblock, "")
+			c.builder.SetInsertPointAtEnd(contBlock)
+			return result
+		}
+	}
+	return c.builder.CreateCall(fn, args, "")
+}
+
+// makeLandingPad creates the landing pad for a function with deferred
+// calls. Panics are implemented as a forced unwind, which transfers control
+// to the landing pad of each function with deferred calls. The landing pad
+// runs the function's deferred calls, and then returns from the function if
+// one of them recovered the panic, or otherwise resumes unwinding.
+func (c *compiler) makeLandingPad(f *function) llvm.BasicBlock {
+	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+
+	llvm_fn := f.LLVMValue()
+	unwindBlock := llvm.AddBasicBlock(llvm_fn, "unwind")
+	recoveredBlock := llvm.AddBasicBlock(llvm_fn, "recovered")
+	resumeBlock := llvm.AddBasicBlock(llvm_fn, "resume")
+
+	c.builder.SetInsertPointAtEnd(unwindBlock)
+	i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
+	personalityType := llvm.FunctionType(llvm.Int32Type(), nil, true)
+	personality := c.namedFunction("__gcc_personality_v0", personalityType)
+	lpType := llvm.StructType([]llvm.Type{i8ptr, llvm.Int32Type()}, false)
+	lp := c.builder.CreateLandingPad(lpType, personality, 0, "")
+	lp.SetCleanup(true)
+
+	ptrType := c.target.IntPtrType()
+	args := []llvm.Value{c.builder.CreatePtrToInt(f.deferlist, ptrType, "")}
+	c.builder.CreateCall(c.rundefersFunction(), args, "")
+	funcType := llvm.FunctionType(llvm.Int1Type(), nil, false)
+	panicrecovered := c.namedFunction("runtime.panicrecovered", funcType)
+	recovered := c.builder.CreateCall(panicrecovered, nil, "")
+	c.builder.CreateCondBr(recovered, recoveredBlock, resumeBlock)
+
+	// The panic was recovered: return the current values of the results.
+	c.builder.SetInsertPointAtEnd(recoveredBlock)
+	c.returnResults(f)
+
+	c.builder.SetInsertPointAtEnd(resumeBlock)
+	c.builder.CreateResume(lp)
+	return unwindBlock
+}
+
+// VisitPanic converts the argument of panic to an interface{} value, and
+// calls runtime.gopanic with its type and value.
+func (c *compiler) VisitPanic(expr *ast.CallExpr) Value {
+	if len(expr.Args) != 1 {
+		panic("Expecting exactly one argument to panic")
+	}
+	arg := c.VisitExpr(expr.Args[0]).Convert(&types.Interface{})
+	iface := arg.LLVMValue()
+
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.VoidType(), paramTypes, false)
+	gopanic := c.namedFunction("runtime.gopanic", funcType)
+	value := c.builder.CreateExtractValue(iface, 0, "")
+	typ := c.builder.CreateExtractValue(iface, 1, "")
+	args := []llvm.Value{
+		c.builder.CreatePtrToInt(typ, ptrType, ""),
+		c.builder.CreatePtrToInt(value, ptrType, "")}
+	c.createCall(gopanic, args)
+	c.builder.CreateUnreachable()
+
+	c.startUnreachableBlock()
+	return nil
+}
+
+// containsRecover reports whether a function body calls recover, excluding
+// calls within nested function literals.
+func containsRecover(body *ast.BlockStmt) bool {
+	recover := types.Universe.Lookup("recover")
+	found := false
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			if ident, ok := node.Fun.(*ast.Ident); ok && ident.Obj == recover {
+				found = true
+			}
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// canRecover generates a call to runtime.canrecover on entry to fn, a
+// function which calls recover, to determine whether fn was called directly
+// by a deferred call.
+func (c *compiler) canRecover(fn llvm.Value) llvm.Value {
+	ptrType := c.target.IntPtrType()
+	funcType := llvm.FunctionType(llvm.Int1Type(), []llvm.Type{ptrType}, false)
+	canrecover := c.namedFunction("runtime.canrecover", funcType)
+	args := []llvm.Value{c.builder.CreatePtrToInt(fn, ptrType, "")}
+	return c.builder.CreateCall(canrecover, args, "")
+}
+
+// VisitRecover calls runtime.gorecover, which stops the current panic (if
+// any) and returns its value as an interface{}. Only a function called
+// directly by a deferred call may stop a panic.
+func (c *compiler) VisitRecover(expr *ast.CallExpr) Value {
+	canrecover := c.functions[len(c.functions)-1].canrecover
+	if canrecover.IsNil() {
+		canrecover = llvm.ConstNull(llvm.Int1Type())
+	}
+	ptrType := c.target.IntPtrType()
+	resultType := llvm.StructType([]llvm.Type{ptrType, ptrType}, false)
+	funcType := llvm.FunctionType(resultType, []llvm.Type{llvm.Int1Type()}, false)
+	gorecover := c.namedFunction("runtime.gorecover", funcType)
+	result := c.createCall(gorecover, []llvm.Value{canrecover})
+
+	typ := &types.Interface{}
+	ifaceType := c.types.ToLLVM(typ)
+	elementTypes := ifaceType.StructElementTypes()
+	value := c.builder.CreateExtractValue(result, 0, "")
+	value = c.builder.CreateIntToPtr(value, elementTypes[0], "")
+	runtimeType := c.builder.CreateExtractValue(result, 1, "")
+	runtimeType = c.builder.CreateIntToPtr(runtimeType, elementTypes[1], "")
+	iface := llvm.ConstNull(ifaceType)
+	iface = c.builder.CreateInsertValue(iface, value, 0, "")
+	iface = c.builder.CreateInsertValue(iface, runtimeType, 1, "")
+	return c.NewLLVMValue(iface, typ)
+}
+
+// vim: set ft=go :
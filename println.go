@@ -63,6 +63,19 @@ func (c *compiler) getBoolString(v llvm.Value) llvm.Value {
 	return result
 }
 
+// floatString formats a floating point value as a string with
+// runtime.floatstring, in the same way as the gc runtime's println.
+func (c *compiler) floatString(v llvm.Value) llvm.Value {
+	if v.Type().TypeKind() == llvm.FloatTypeKind {
+		v = c.builder.CreateFPExt(v, llvm.DoubleType(), "")
+	}
+	stringType := c.types.ToLLVM(types.String)
+	paramTypes := []llvm.Type{llvm.DoubleType()}
+	funcType := llvm.FunctionType(stringType, paramTypes, false)
+	floatstring := c.namedFunction("runtime.floatstring", funcType)
+	return c.createCall(floatstring, []llvm.Value{v})
+}
+
 func (c *compiler) VisitPrintln(expr *ast.CallExpr) Value {
 	var args []llvm.Value = nil
 	if expr.Args != nil {
@@ -86,6 +99,10 @@ func (c *compiler) VisitPrintln(expr *ast.CallExpr) Value {
 				switch typ.Kind {
 				case types.UintKind:
 					format += "%lu"
+				case types.Uint8Kind:
+					// Variadic arguments are promoted to int.
+					format += "%u"
+					llvm_value = c.builder.CreateZExt(llvm_value, llvm.Int32Type(), "")
 				case types.Uint16Kind:
 					format += "%hu"
 				case types.Uint32Kind, types.UintptrKind: // FIXME uintptr to become bitwidth dependent
@@ -94,12 +111,29 @@ func (c *compiler) VisitPrintln(expr *ast.CallExpr) Value {
 					format += "%llu" // FIXME windows
 				case types.IntKind:
 					format += "%ld"
+				case types.Int8Kind:
+					format += "%d"
+					llvm_value = c.builder.CreateSExt(llvm_value, llvm.Int32Type(), "")
 				case types.Int16Kind:
 					format += "%hd"
 				case types.Int32Kind:
 					format += "%d"
 				case types.Int64Kind:
 					format += "%lld" // FIXME windows
+				case types.Float32Kind, types.Float64Kind:
+					str := c.floatString(llvm_value)
+					args = append(args, c.builder.CreateExtractValue(str, 1, ""))
+					llvm_value = c.builder.CreateExtractValue(str, 0, "")
+					format += "%.*s"
+				case types.Complex64Kind, types.Complex128Kind:
+					re, im := c.complexParts(value)
+					restr, imstr := c.floatString(re), c.floatString(im)
+					args = append(args,
+						c.builder.CreateExtractValue(restr, 1, ""),
+						c.builder.CreateExtractValue(restr, 0, ""),
+						c.builder.CreateExtractValue(imstr, 1, ""))
+					llvm_value = c.builder.CreateExtractValue(imstr, 0, "")
+					format += "(%.*s%.*si)"
 				case types.StringKind:
 					ptrval := c.builder.CreateExtractValue(llvm_value, 0, "")
 					lenval := c.builder.CreateExtractValue(llvm_value, 1, "")
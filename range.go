@@ -0,0 +1,281 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/token"
+)
+
+// rangeLoop holds the basic blocks of a lowered range statement. The
+// "cond" block determines whether there is another iteration, the "body"
+// block assigns the iteration values and executes the statement body, and
+// the "post" block advances to the next iteration.
+type rangeLoop struct {
+	cond, body, post, done llvm.BasicBlock
+}
+
+func (c *compiler) newRangeLoop() *rangeLoop {
+	fn := c.builder.GetInsertBlock().Parent()
+	l := &rangeLoop{}
+	l.cond = llvm.AddBasicBlock(fn, "rangecond")
+	l.body = llvm.AddBasicBlock(fn, "rangebody")
+	l.post = llvm.AddBasicBlock(fn, "rangepost")
+	l.done = llvm.AddBasicBlock(fn, "rangedone")
+	return l
+}
+
+// rangeAssign assigns the iteration values of a range statement to the
+// key and value expressions. Either value may be nil, if the corresponding
+// expression is absent or the blank identifier.
+func (c *compiler) rangeAssign(stmt *ast.RangeStmt, key, value Value) {
+	var lhs []ast.Expr
+	var values []Value
+	if stmt.Key != nil && key != nil {
+		lhs = append(lhs, stmt.Key)
+		values = append(values, key)
+	}
+	if stmt.Value != nil && value != nil {
+		lhs = append(lhs, stmt.Value)
+		values = append(values, value)
+	}
+	c.assign(lhs, values, false)
+}
+
+// rangeDefine declares the variables of a range statement with a short
+// variable declaration ("for k, v := range x"). The variables are declared
+// once, before the loop, and assigned to in each iteration.
+func (c *compiler) rangeDefine(stmt *ast.RangeStmt, keyType, valueType types.Type) {
+	if stmt.Tok != token.DEFINE {
+		return
+	}
+	var lhs []ast.Expr
+	var values []Value
+	if stmt.Key != nil {
+		lhs = append(lhs, stmt.Key)
+		zero := llvm.ConstNull(c.types.ToLLVM(keyType))
+		values = append(values, c.NewLLVMValue(zero, keyType))
+	}
+	if stmt.Value != nil && valueType != nil {
+		lhs = append(lhs, stmt.Value)
+		zero := llvm.ConstNull(c.types.ToLLVM(valueType))
+		values = append(values, c.NewLLVMValue(zero, valueType))
+	}
+	c.assign(lhs, values, true)
+}
+
+// rangeBody assigns the iteration values, visits the body of a range
+// statement, and then branches to the post block.
+func (c *compiler) rangeBody(stmt *ast.RangeStmt, l *rangeLoop, key, value Value) {
+	c.builder.SetInsertPointAtEnd(l.body)
+	c.rangeAssign(stmt, key, value)
+	c.pushBranchTarget(l.done, l.post)
+	c.VisitBlockStmt(stmt.Body)
+	c.popBranchTarget()
+	c.maybeBranch(l.post)
+}
+
+func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
+	c.PushScope()
+	defer c.PopScope()
+
+	// The range expression is evaluated once, before the loop.
+	x := c.VisitExpr(stmt.X)
+	typ := types.Underlying(x.Type())
+	if name, isname := typ.(*types.Name); isname {
+		typ = name.Underlying
+	}
+	switch typ := typ.(type) {
+	case *types.Array:
+		c.rangeIndexed(stmt, x, typ.Elt, llvm.ConstInt(c.target.IntPtrType(), typ.Len, false))
+	case *types.Pointer:
+		array := types.Underlying(typ.Base).(*types.Array)
+		c.rangeIndexed(stmt, x, array.Elt, llvm.ConstInt(c.target.IntPtrType(), array.Len, false))
+	case *types.Slice:
+		length := c.builder.CreateExtractValue(x.LLVMValue(), 1, "")
+		length = c.NewLLVMValue(length, types.Int32).Convert(types.Int).LLVMValue()
+		c.rangeIndexed(stmt, x, typ.Elt, length)
+	case *types.Basic:
+		if typ != types.String.Underlying {
+			panic(fmt.Sprint("invalid type for range: ", typ))
+		}
+		c.rangeString(stmt, x)
+	case *types.Map:
+		c.rangeMap(stmt, x, typ)
+	case *types.Chan:
+		c.rangeChan(stmt, x.(*LLVMValue), typ)
+	default:
+		panic(fmt.Sprint("invalid type for range: ", typ))
+	}
+}
+
+// rangeIndexed lowers a range statement over an array, pointer to array
+// or slice, with the specified number of elements.
+func (c *compiler) rangeIndexed(stmt *ast.RangeStmt, x Value, elttyp types.Type, length llvm.Value) {
+	intType := c.target.IntPtrType()
+	c.rangeDefine(stmt, types.Int, elttyp)
+
+	// Get a pointer to the first element. Arrays are copied, as the range
+	// expression is evaluated only once. The copy and the loop index are
+	// allocated in the entry block, so that nested loops reuse them.
+	var base llvm.Value
+	var indices []llvm.Value
+	switch types.Underlying(x.Type()).(type) {
+	case *types.Array:
+		base = c.entryAlloca(c.types.ToLLVM(x.Type()), "")
+		c.builder.CreateStore(x.LLVMValue(), base)
+		indices = []llvm.Value{llvm.ConstNull(llvm.Int32Type())}
+	case *types.Pointer:
+		base = x.LLVMValue()
+		indices = []llvm.Value{llvm.ConstNull(llvm.Int32Type())}
+	case *types.Slice:
+		base = c.builder.CreateExtractValue(x.LLVMValue(), 0, "")
+	}
+
+	index := c.entryAlloca(intType, "index")
+	c.builder.CreateStore(llvm.ConstNull(intType), index)
+	l := c.newRangeLoop()
+	c.builder.CreateBr(l.cond)
+
+	c.builder.SetInsertPointAtEnd(l.cond)
+	i := c.builder.CreateLoad(index, "")
+	more := c.builder.CreateICmp(llvm.IntSLT, i, length, "")
+	c.builder.CreateCondBr(more, l.body, l.done)
+
+	c.builder.SetInsertPointAtEnd(l.body)
+	var value Value
+	if stmt.Value != nil {
+		indices = append(indices, i)
+		element := c.builder.CreateLoad(c.builder.CreateGEP(base, indices, ""), "")
+		value = c.NewLLVMValue(element, elttyp)
+	}
+	c.rangeBody(stmt, l, c.NewLLVMValue(i, types.Int), value)
+
+	c.builder.SetInsertPointAtEnd(l.post)
+	i = c.builder.CreateLoad(index, "")
+	i = c.builder.CreateAdd(i, llvm.ConstInt(intType, 1, false), "")
+	c.builder.CreateStore(i, index)
+	c.builder.CreateBr(l.cond)
+	c.builder.SetInsertPointAtEnd(l.done)
+}
+
+// rangeString lowers a range statement over a string, which decodes a
+// UTF-8 encoded rune in each iteration. The key is the byte index of the
+// rune, and the value is the rune.
+func (c *compiler) rangeString(stmt *ast.RangeStmt, x Value) {
+	intType := c.target.IntPtrType()
+	stringType := c.types.ToLLVM(types.String)
+	resultType := llvm.StructType([]llvm.Type{llvm.Int32Type(), intType}, false)
+	paramTypes := []llvm.Type{stringType, intType}
+	funcType := llvm.FunctionType(resultType, paramTypes, false)
+	strnext := c.namedFunction("runtime.strnext", funcType)
+	c.rangeDefine(stmt, types.Int, types.Rune)
+
+	str := x.LLVMValue()
+	length := c.builder.CreateExtractValue(str, 1, "")
+	length = c.NewLLVMValue(length, types.Int32).Convert(types.Int).LLVMValue()
+	index := c.entryAlloca(intType, "index")
+	next := c.entryAlloca(intType, "next")
+	c.builder.CreateStore(llvm.ConstNull(intType), index)
+	l := c.newRangeLoop()
+	c.builder.CreateBr(l.cond)
+
+	c.builder.SetInsertPointAtEnd(l.cond)
+	i := c.builder.CreateLoad(index, "")
+	more := c.builder.CreateICmp(llvm.IntSLT, i, length, "")
+	c.builder.CreateCondBr(more, l.body, l.done)
+
+	c.builder.SetInsertPointAtEnd(l.body)
+	result := c.builder.CreateCall(strnext, []llvm.Value{str, i}, "")
+	r := c.builder.CreateExtractValue(result, 0, "")
+	c.builder.CreateStore(c.builder.CreateExtractValue(result, 1, ""), next)
+	c.rangeBody(stmt, l, c.NewLLVMValue(i, types.Int), c.NewLLVMValue(r, types.Rune))
+
+	c.builder.SetInsertPointAtEnd(l.post)
+	c.builder.CreateStore(c.builder.CreateLoad(next, ""), index)
+	c.builder.CreateBr(l.cond)
+	c.builder.SetInsertPointAtEnd(l.done)
+}
+
+// rangeMap lowers a range statement over a map, by iterating over the
+// map's entries with runtime.mapiterinit and runtime.mapiternext.
+func (c *compiler) rangeMap(stmt *ast.RangeStmt, x Value, typ *types.Map) {
+	c.rangeDefine(stmt, typ.Key, typ.Elt)
+
+	ptrType := c.target.IntPtrType()
+	funcType := llvm.FunctionType(ptrType, []llvm.Type{ptrType}, false)
+	mapiterinit := c.namedFunction("runtime.mapiterinit", funcType)
+	resultType := llvm.StructType([]llvm.Type{ptrType, ptrType}, false)
+	funcType = llvm.FunctionType(resultType, []llvm.Type{ptrType}, false)
+	mapiternext := c.namedFunction("runtime.mapiternext", funcType)
+
+	args := []llvm.Value{c.builder.CreatePtrToInt(x.LLVMValue(), ptrType, "")}
+	iter := c.createCall(mapiterinit, args)
+	l := c.newRangeLoop()
+	c.builder.CreateBr(l.cond)
+
+	// mapiternext returns pointers to the next entry's key and element, or
+	// nil pointers when there are no more entries.
+	c.builder.SetInsertPointAtEnd(l.cond)
+	result := c.createCall(mapiternext, []llvm.Value{iter})
+	keyptr := c.builder.CreateExtractValue(result, 0, "")
+	elemptr := c.builder.CreateExtractValue(result, 1, "")
+	more := c.builder.CreateIsNotNull(keyptr, "")
+	c.builder.CreateCondBr(more, l.body, l.done)
+
+	c.builder.SetInsertPointAtEnd(l.body)
+	keyType := llvm.PointerType(c.types.ToLLVM(typ.Key), 0)
+	elemType := llvm.PointerType(c.types.ToLLVM(typ.Elt), 0)
+	key := c.builder.CreateLoad(c.builder.CreateIntToPtr(keyptr, keyType, ""), "")
+	value := c.builder.CreateLoad(c.builder.CreateIntToPtr(elemptr, elemType, ""), "")
+	c.rangeBody(stmt, l, c.NewLLVMValue(key, typ.Key), c.NewLLVMValue(value, typ.Elt))
+
+	c.builder.SetInsertPointAtEnd(l.post)
+	c.builder.CreateBr(l.cond)
+	c.builder.SetInsertPointAtEnd(l.done)
+}
+
+// rangeChan lowers a range statement over a channel, which receives values
+// until the channel is closed.
+func (c *compiler) rangeChan(stmt *ast.RangeStmt, ch *LLVMValue, typ *types.Chan) {
+	c.rangeDefine(stmt, typ.Elt, nil)
+	l := c.newRangeLoop()
+	c.builder.CreateBr(l.cond)
+
+	c.builder.SetInsertPointAtEnd(l.cond)
+	result := c.chanRecv(ch, true).LLVMValue()
+	value := c.builder.CreateExtractValue(result, 0, "")
+	ok := c.builder.CreateExtractValue(result, 1, "")
+	c.builder.CreateCondBr(ok, l.body, l.done)
+
+	c.rangeBody(stmt, l, c.NewLLVMValue(value, typ.Elt), nil)
+	c.builder.SetInsertPointAtEnd(l.post)
+	c.builder.CreateBr(l.cond)
+	c.builder.SetInsertPointAtEnd(l.done)
+}
+
+// vim: set ft=go :
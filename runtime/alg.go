@@ -0,0 +1,240 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// typeAlg is the algorithm table of a type, pointed to by commonType.alg.
+// Its functions are as in the gc runtime's Alg structure, except that the
+// compiler stores them as func values. The hash and equal functions of
+// types which are not comparable are nil, as are the print functions of
+// types other than floats, complex numbers and strings.
+type typeAlg struct {
+	hash  func(h *uintptr, size uintptr, p unsafe.Pointer)
+	equal func(eq *bool, size uintptr, a, b unsafe.Pointer)
+	print func(size uintptr, p unsafe.Pointer)
+	copy  func(size uintptr, dst, src unsafe.Pointer)
+}
+
+// eface is the runtime representation of an interface value: the value
+// pointer, followed by either the dynamic type (for empty interfaces) or
+// the itab (for non-empty interfaces).
+type eface struct {
+	value unsafe.Pointer
+	typ   unsafe.Pointer
+}
+
+// nanhash is updated each time a NaN is hashed. NaNs are never equal to
+// each other, so giving them distinct hashes avoids long hash chains in
+// maps with many NaN keys.
+var nanhash uintptr
+
+func memhash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	hash := *h
+	for i := uintptr(0); i < size; i++ {
+		b := *(*uint8)(unsafe.Pointer(uintptr(p) + i))
+		hash = hash*31 + uintptr(b)
+	}
+	*h = hash
+}
+
+func memequal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	for i := uintptr(0); i < size; i++ {
+		c1 := *(*uint8)(unsafe.Pointer(uintptr(a) + i))
+		c2 := *(*uint8)(unsafe.Pointer(uintptr(b) + i))
+		if c1 != c2 {
+			*eq = false
+			return
+		}
+	}
+	*eq = true
+}
+
+// memcopy copies a value, or zeroes it if src is nil.
+func memcopy(size uintptr, dst, src unsafe.Pointer) {
+	if src == nil {
+		memset(dst, 0, int(size))
+		return
+	}
+	memcpy(dst, src, int(size))
+}
+
+func strhash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	s := (*str)(p)
+	memhash(h, uintptr(s.size), unsafe.Pointer(s.ptr))
+}
+
+func strequal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	*eq = *(*string)(a) == *(*string)(b)
+}
+
+func strprint(size uintptr, p unsafe.Pointer) {
+	println(*(*string)(p))
+}
+
+// f32hash and f64hash hash positive and negative zero alike, as they are
+// equal, and give each NaN a new hash.
+func f32hash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	f := *(*float32)(p)
+	switch {
+	case f == 0:
+		*h = *h * 31
+	case f != f:
+		nanhash++
+		*h = *h*31 + nanhash
+	default:
+		memhash(h, size, p)
+	}
+}
+
+func f32equal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	*eq = *(*float32)(a) == *(*float32)(b)
+}
+
+func f32print(size uintptr, p unsafe.Pointer) {
+	println(*(*float32)(p))
+}
+
+func f64hash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	f := *(*float64)(p)
+	switch {
+	case f == 0:
+		*h = *h * 31
+	case f != f:
+		nanhash++
+		*h = *h*31 + nanhash
+	default:
+		memhash(h, size, p)
+	}
+}
+
+func f64equal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	*eq = *(*float64)(a) == *(*float64)(b)
+}
+
+func f64print(size uintptr, p unsafe.Pointer) {
+	println(*(*float64)(p))
+}
+
+// c64hash and c128hash hash the real and imaginary parts in turn.
+func c64hash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	f32hash(h, size/2, p)
+	f32hash(h, size/2, unsafe.Pointer(uintptr(p)+size/2))
+}
+
+func c64equal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	*eq = *(*complex64)(a) == *(*complex64)(b)
+}
+
+func c64print(size uintptr, p unsafe.Pointer) {
+	println(*(*complex64)(p))
+}
+
+func c128hash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	f64hash(h, size/2, p)
+	f64hash(h, size/2, unsafe.Pointer(uintptr(p)+size/2))
+}
+
+func c128equal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	*eq = *(*complex128)(a) == *(*complex128)(b)
+}
+
+func c128print(size uintptr, p unsafe.Pointer) {
+	println(*(*complex128)(p))
+}
+
+// ifacevalue returns a pointer to the dynamic value of an interface whose
+// dynamic type is t. Values no larger than a pointer are stored in the
+// value pointer itself.
+func ifacevalue(t *commonType, value *unsafe.Pointer) unsafe.Pointer {
+	if t.size <= ptrsize() {
+		return unsafe.Pointer(value)
+	}
+	return *value
+}
+
+// ifacehash hashes the dynamic value of an interface, panicking if its
+// dynamic type is not comparable.
+func ifacehash(h *uintptr, typ unsafe.Pointer, value *unsafe.Pointer) {
+	if typ == nil {
+		*h = *h * 31
+		return
+	}
+	t := (*commonType)(typ)
+	if t.alg.hash == nil {
+		panic("runtime error: hash of unhashable type " + typestring(t))
+	}
+	t.alg.hash(h, t.size, ifacevalue(t, value))
+}
+
+func nilinterhash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	e := (*eface)(p)
+	ifacehash(h, e.typ, &e.value)
+}
+
+func interhash(h *uintptr, size uintptr, p unsafe.Pointer) {
+	e := (*eface)(p)
+	var typ unsafe.Pointer
+	if e.typ != nil {
+		typ = (*itab)(e.typ).typ
+	}
+	ifacehash(h, typ, &e.value)
+}
+
+// ifaceeq reports whether two interface values, with the dynamic types t1
+// and t2 and value pointers v1 and v2, are equal. Comparing two values of
+// the same dynamic type which is not comparable causes a run-time panic.
+func ifaceeq(t1, v1, t2, v2 unsafe.Pointer) bool {
+	if t1 != t2 {
+		return false
+	}
+	if t1 == nil {
+		return true
+	}
+	t := (*commonType)(t1)
+	if t.alg.equal == nil {
+		panic("runtime error: comparing uncomparable type " + typestring(t))
+	}
+	var eq bool
+	t.alg.equal(&eq, t.size, ifacevalue(t, &v1), ifacevalue(t, &v2))
+	return eq
+}
+
+func nilinterequal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	e1, e2 := (*eface)(a), (*eface)(b)
+	*eq = ifaceeq(e1.typ, e1.value, e2.typ, e2.value)
+}
+
+func interequal(eq *bool, size uintptr, a, b unsafe.Pointer) {
+	e1, e2 := (*eface)(a), (*eface)(b)
+	var t1, t2 unsafe.Pointer
+	if e1.typ != nil {
+		t1 = (*itab)(e1.typ).typ
+	}
+	if e2.typ != nil {
+		t2 = (*itab)(e2.typ).typ
+	}
+	*eq = ifaceeq(t1, e1.value, t2, e2.value)
+}
+
+// vim: set ft=go:
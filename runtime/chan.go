@@ -0,0 +1,198 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// _chan is the runtime representation of a channel. Elements are stored in
+// a circular buffer of "cap" elements; unbuffered channels have a single
+// element buffer, which a sender fills and then waits to be emptied.
+type _chan struct {
+	elemsize int
+	cap      int
+	len      int
+	head     int
+	closed   bool
+	// The number of values sent and received, used by senders on
+	// unbuffered channels to wait for their value to be received.
+	sent     int
+	received int
+	// The number of receivers blocked on the channel, and the select
+	// statements blocked with a receive case on the channel. A send on an
+	// unbuffered channel in a select statement may only proceed if there
+	// is a receiver or select statement waiting to take the value.
+	recvwaiting int
+	selwaiters  *_selnode
+	buf         unsafe.Pointer
+}
+
+// All channel operations are serialised by a single lock. Blocked senders
+// and receivers wait on a condition which is signalled whenever a channel's
+// state changes.
+func chanlock()
+func chanunlock()
+func chanwait()
+func chansignal()
+
+func memset(dst unsafe.Pointer, fill uint8, size int)
+
+func chanslots(c *_chan) int {
+	if c.cap == 0 {
+		return 1
+	}
+	return c.cap
+}
+
+func chanslot(c *_chan, i int) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(c.buf) + uintptr(i*c.elemsize))
+}
+
+func chanmake(elemsize, cap int) unsafe.Pointer {
+	c := new(_chan)
+	c.elemsize = elemsize
+	c.cap = cap
+	c.buf = malloc(chanslots(c) * elemsize)
+	return unsafe.Pointer(c)
+}
+
+func chansend(ch, elem unsafe.Pointer) {
+	c := (*_chan)(ch)
+	chanlock()
+	for c == nil {
+		// Sending on a nil channel blocks forever.
+		chanwait()
+	}
+	chansendlocked(c, elem)
+	chanunlock()
+}
+
+// chansendlocked sends a value on a channel. The runtime lock must be held.
+func chansendlocked(c *_chan, elem unsafe.Pointer) {
+	slots := chanslots(c)
+	for !c.closed && c.len == slots {
+		chanwait()
+	}
+	if c.closed {
+		chanunlock()
+		panic("send on closed channel")
+	}
+
+	i := c.head + c.len
+	if i >= slots {
+		i = i - slots
+	}
+	memcpy(chanslot(c, i), elem, c.elemsize)
+	c.len++
+	c.sent++
+	chansignal()
+
+	// Unbuffered channels are synchronous: wait for the receiver. If the
+	// channel is closed first, then take the value back out of the buffer,
+	// so that no receiver sees it.
+	if c.cap == 0 {
+		sent := c.sent
+		for !c.closed && c.received < sent {
+			chanwait()
+		}
+		if c.received < sent {
+			c.len--
+			c.sent--
+			chanunlock()
+			panic("send on closed channel")
+		}
+	}
+}
+
+func chanrecv(ch, elem unsafe.Pointer) bool {
+	c := (*_chan)(ch)
+	chanlock()
+	for c == nil {
+		// Receiving from a nil channel blocks forever.
+		chanwait()
+	}
+	ok := chanrecvlocked(c, elem)
+	chanunlock()
+	return ok
+}
+
+// chanrecvlocked receives a value from a channel. The runtime lock must be
+// held.
+func chanrecvlocked(c *_chan, elem unsafe.Pointer) bool {
+	c.recvwaiting++
+	chansignal()
+	for !c.closed && c.len == 0 {
+		chanwait()
+	}
+	c.recvwaiting--
+	if c.len == 0 {
+		// Closed and drained; yield the zero value.
+		memset(elem, 0, c.elemsize)
+		return false
+	}
+
+	memcpy(elem, chanslot(c, c.head), c.elemsize)
+	c.head++
+	if c.head == chanslots(c) {
+		c.head = 0
+	}
+	c.len--
+	c.received++
+	chansignal()
+	return true
+}
+
+func chanclose(ch unsafe.Pointer) {
+	c := (*_chan)(ch)
+	if c == nil {
+		panic("close of nil channel")
+	}
+	chanlock()
+	if c.closed {
+		chanunlock()
+		panic("close of closed channel")
+	}
+	c.closed = true
+	chansignal()
+	chanunlock()
+}
+
+func chanlen(ch unsafe.Pointer) int {
+	c := (*_chan)(ch)
+	if c == nil || c.cap == 0 {
+		return 0
+	}
+	chanlock()
+	n := c.len
+	chanunlock()
+	return n
+}
+
+func chancap(ch unsafe.Pointer) int {
+	c := (*_chan)(ch)
+	if c == nil {
+		return 0
+	}
+	return c.cap
+}
+
+// vim: set ft=go:
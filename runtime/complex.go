@@ -0,0 +1,106 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+func isnan(f float64) bool {
+	return f != f
+}
+
+func isfinite(f float64) bool {
+	return !isnan(f - f)
+}
+
+func isinf(f float64) bool {
+	return !isnan(f) && !isfinite(f)
+}
+
+func posinf() float64 {
+	f := 1.7976931348623157e308
+	return f * 2
+}
+
+func fabs(f float64) float64 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// copysign returns x with the sign of y. The sign of a zero y is that of
+// 1/y, which is an infinity of the same sign.
+func copysign(x, y float64) float64 {
+	x = fabs(x)
+	if y < 0 || (y == 0 && 1/y < 0) {
+		return -x
+	}
+	return x
+}
+
+func inf2one(f float64) float64 {
+	g := 0.0
+	if isinf(f) {
+		g = 1.0
+	}
+	return copysign(g, f)
+}
+
+// complex128div returns the quotient (e + fi) of n = a + bi and
+// m = c + di, using the same algorithm as the gc runtime. The result is
+// computed as described in Robert L. Smith: Algorithm 116: Complex
+// division. Commun. ACM 5(8): 435 (1962), and then corrected to infinities
+// and zeros as in C99 (ISO/IEC 9899:1999, G.5.1) if both parts are NaN.
+func complex128div(a, b, c, d float64) (float64, float64) {
+	var e, f float64
+	if fabs(c) >= fabs(d) {
+		ratio := d / c
+		denom := c + ratio*d
+		e = (a + b*ratio) / denom
+		f = (b - a*ratio) / denom
+	} else {
+		ratio := c / d
+		denom := d + ratio*c
+		e = (a*ratio + b) / denom
+		f = (b*ratio - a) / denom
+	}
+
+	if isnan(e) && isnan(f) {
+		switch {
+		case c == 0 && d == 0 && (!isnan(a) || !isnan(b)):
+			e = copysign(posinf(), c) * a
+			f = copysign(posinf(), c) * b
+		case (isinf(a) || isinf(b)) && isfinite(c) && isfinite(d):
+			a = inf2one(a)
+			b = inf2one(b)
+			e = posinf() * (a*c + b*d)
+			f = posinf() * (b*c - a*d)
+		case (isinf(c) || isinf(d)) && isfinite(a) && isfinite(b):
+			c = inf2one(c)
+			d = inf2one(d)
+			e = 0 * (a*c + b*d)
+			f = 0 * (b*c - a*d)
+		}
+	}
+	return e, f
+}
+
+// vim: set ft=go:
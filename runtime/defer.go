@@ -0,0 +1,49 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package runtime
+
+import "unsafe"
+
+// _defer is the header of a deferred call record. The compiler generates
+// records with the function and arguments of the call following the
+// header; the function f takes a pointer to the record and performs the
+// call.
+type _defer struct {
+	next *_defer
+	f    func(unsafe.Pointer)
+}
+
+// rundefers runs the deferred calls in a function's defer list, in
+// last-in first-out order. Each record is removed from the list before it
+// is called.
+func rundefers(list unsafe.Pointer) {
+	head := (**_defer)(list)
+	for *head != nil {
+		d := *head
+		*head = d.next
+		d.f(unsafe.Pointer(d))
+	}
+}
+
+// vim: set ft=go:
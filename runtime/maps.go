@@ -0,0 +1,271 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// _map is the runtime representation of a map. Entries are stored in a
+// hash table of chained buckets, which is doubled in size whenever the
+// number of entries reaches the number of buckets. The entries are also
+// kept in a list in the order they were inserted, which iterators follow,
+// so that growing the table does not disturb iteration.
+type _map struct {
+	keysize  int
+	elemsize int
+	elemoff  int      // offset of the element from the key in an entry
+	keyalg   *typeAlg // hashes and compares keys
+	count    int
+	nbuckets int
+	buckets  unsafe.Pointer // [nbuckets]*mapentry
+	first    *mapentry      // the first entry in insertion order
+	last     *mapentry      // the last entry in insertion order
+}
+
+// mapentry is an entry in a map's bucket list. The key and element are
+// stored together in a separately allocated block of memory.
+type mapentry struct {
+	next     *mapentry // next entry in the same bucket
+	hash     uintptr
+	key      unsafe.Pointer
+	elem     unsafe.Pointer
+	listnext *mapentry // next entry in insertion order
+	listprev *mapentry // previous entry in insertion order
+	deleted  bool
+}
+
+// mapiter holds the state of a range statement over a map.
+type mapiter struct {
+	m     *_map
+	entry *mapentry // the entry last returned, if any
+}
+
+// ptrsize returns the size of a pointer, in bytes.
+func ptrsize() uintptr {
+	var p [2]unsafe.Pointer
+	return uintptr(unsafe.Pointer(&p[1])) - uintptr(unsafe.Pointer(&p[0]))
+}
+
+// mapbucket returns a pointer to the i'th bucket of a map.
+func mapbucket(m *_map, i uintptr) **mapentry {
+	return (**mapentry)(unsafe.Pointer(uintptr(m.buckets) + i*ptrsize()))
+}
+
+// mapindex returns the index of the bucket for the given hash.
+func mapindex(m *_map, h uintptr) uintptr {
+	n := uintptr(m.nbuckets)
+	return h - h/n*n
+}
+
+func mapalloc(m *_map, nbuckets int) {
+	size := nbuckets * int(ptrsize())
+	m.buckets = malloc(size)
+	memset(m.buckets, 0, size)
+	m.nbuckets = nbuckets
+}
+
+func maphash(m *_map, key unsafe.Pointer) uintptr {
+	var h uintptr
+	m.keyalg.hash(&h, uintptr(m.keysize), key)
+	return h
+}
+
+func mapkeyequal(m *_map, a, b unsafe.Pointer) bool {
+	var eq bool
+	m.keyalg.equal(&eq, uintptr(m.keysize), a, b)
+	return eq
+}
+
+// mapfind returns the entry for a key with the given hash, or nil if the
+// key is not present.
+func mapfind(m *_map, key unsafe.Pointer, h uintptr) *mapentry {
+	e := *mapbucket(m, mapindex(m, h))
+	for e != nil {
+		if e.hash == h && mapkeyequal(m, e.key, key) {
+			return e
+		}
+		e = e.next
+	}
+	return nil
+}
+
+// mapgrow doubles the number of buckets in a map, and redistributes the
+// entries among the new buckets. The list of entries followed by
+// iterators is unchanged.
+func mapgrow(m *_map) {
+	oldbuckets := m.buckets
+	oldn := m.nbuckets
+	mapalloc(m, oldn*2)
+	for i := 0; i < oldn; i++ {
+		e := *(**mapentry)(unsafe.Pointer(uintptr(oldbuckets) + uintptr(i)*ptrsize()))
+		for e != nil {
+			next := e.next
+			bucket := mapbucket(m, mapindex(m, e.hash))
+			e.next = *bucket
+			*bucket = e
+			e = next
+		}
+	}
+}
+
+// mapmake creates a map with keys of the given type and elements of the
+// given size, with space for at least hint entries.
+func mapmake(keytype unsafe.Pointer, elemsize, hint int) unsafe.Pointer {
+	kt := (*commonType)(keytype)
+	m := new(_map)
+	m.keysize = int(kt.size)
+	m.elemsize = elemsize
+	m.elemoff = (m.keysize + 7) / 8 * 8
+	m.keyalg = kt.alg
+	n := 8
+	for n < hint {
+		n = n * 2
+	}
+	mapalloc(m, n)
+	return unsafe.Pointer(m)
+}
+
+// mapaccess returns a pointer to the element for a key, or nil if the key
+// is not present. A nil map behaves like an empty map.
+func mapaccess(mp, key unsafe.Pointer) unsafe.Pointer {
+	m := (*_map)(mp)
+	if m == nil {
+		return nil
+	}
+	e := mapfind(m, key, maphash(m, key))
+	if e == nil {
+		return nil
+	}
+	return e.elem
+}
+
+// mapassign returns a pointer to the element for a key, inserting the key
+// with a zero element if it is not present.
+func mapassign(mp, key unsafe.Pointer) unsafe.Pointer {
+	m := (*_map)(mp)
+	if m == nil {
+		panic("assignment to entry in nil map")
+	}
+	h := maphash(m, key)
+	e := mapfind(m, key, h)
+	if e != nil {
+		return e.elem
+	}
+
+	if m.count >= m.nbuckets {
+		mapgrow(m)
+	}
+	size := m.elemoff + m.elemsize
+	if size == 0 {
+		// Ensure the entry has a unique, non-nil element pointer.
+		size = 1
+	}
+	e = new(mapentry)
+	e.hash = h
+	e.key = malloc(size)
+	e.elem = unsafe.Pointer(uintptr(e.key) + uintptr(m.elemoff))
+	memcpy(e.key, key, m.keysize)
+	memset(e.elem, 0, m.elemsize)
+	bucket := mapbucket(m, mapindex(m, h))
+	e.next = *bucket
+	*bucket = e
+	e.listprev = m.last
+	if m.last != nil {
+		m.last.listnext = e
+	} else {
+		m.first = e
+	}
+	m.last = e
+	m.count++
+	return e.elem
+}
+
+// mapdelete removes the entry for a key, if any. The entry is marked as
+// deleted, and keeps its link to the next entry in insertion order, so
+// that an iterator positioned at the entry may continue past it.
+func mapdelete(mp, key unsafe.Pointer) {
+	m := (*_map)(mp)
+	if m == nil {
+		return
+	}
+	h := maphash(m, key)
+	p := mapbucket(m, mapindex(m, h))
+	for *p != nil {
+		e := *p
+		if e.hash == h && mapkeyequal(m, e.key, key) {
+			*p = e.next
+			if e.listprev != nil {
+				e.listprev.listnext = e.listnext
+			} else {
+				m.first = e.listnext
+			}
+			if e.listnext != nil {
+				e.listnext.listprev = e.listprev
+			} else {
+				m.last = e.listprev
+			}
+			e.deleted = true
+			m.count--
+			return
+		}
+		p = &e.next
+	}
+}
+
+func maplen(mp unsafe.Pointer) int {
+	m := (*_map)(mp)
+	if m == nil {
+		return 0
+	}
+	return m.count
+}
+
+func mapiterinit(mp unsafe.Pointer) unsafe.Pointer {
+	it := new(mapiter)
+	it.m = (*_map)(mp)
+	return unsafe.Pointer(it)
+}
+
+// mapiternext advances a map iterator, returning pointers to the next
+// entry's key and element, or nil pointers if there are no more entries.
+// Entries deleted since the iterator last advanced are skipped.
+func mapiternext(itp unsafe.Pointer) (key, elem unsafe.Pointer) {
+	it := (*mapiter)(itp)
+	if it.m == nil {
+		return nil, nil
+	}
+	e := it.m.first
+	if it.entry != nil {
+		e = it.entry.listnext
+	}
+	for e != nil && e.deleted {
+		e = e.listnext
+	}
+	it.entry = e
+	if e == nil {
+		it.m = nil
+		return nil, nil
+	}
+	return e.key, e.elem
+}
+
+// vim: set ft=go:
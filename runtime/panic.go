@@ -0,0 +1,175 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package runtime
+
+import "unsafe"
+
+// _panic records the value passed to panic. The value and type are the
+// two words of the interface{} value.
+type _panic struct {
+	value     unsafe.Pointer
+	typ       unsafe.Pointer
+	recovered bool
+	link      *_panic // the panic that was in progress, if any
+}
+
+// _g holds the panic state of a goroutine.
+type _g struct {
+	panicking *_panic        // the innermost panic in progress
+	deferfn   unsafe.Pointer // the function last called by a deferred call
+}
+
+// getg returns the state of the calling goroutine. Goroutines are threads,
+// so each goroutine's state is kept in thread-local storage.
+func getg() *_g
+
+// unwind begins unwinding the stack. Each frame with deferred calls has a
+// landing pad which runs them, and then either returns from the function
+// (if the panic was recovered) or continues unwinding. If the unwinding
+// reaches the end of the stack, panicexit is called.
+func unwind()
+
+// exit terminates the process with the given status.
+func exit(code int)
+
+func gopanic(typ, value unsafe.Pointer) {
+	p := new(_panic)
+	p.value = value
+	p.typ = typ
+	g := getg()
+	p.link = g.panicking
+	g.panicking = p
+	unwind()
+}
+
+// deferredcall records that fn is about to be called by a deferred call.
+func deferredcall(fn unsafe.Pointer) {
+	getg().deferfn = fn
+}
+
+// canrecover is called on entry to fn, a function which calls recover. It
+// reports whether fn was called directly by a deferred call, and so may
+// stop a panic.
+func canrecover(fn unsafe.Pointer) bool {
+	g := getg()
+	if g.deferfn == fn {
+		g.deferfn = nil
+		return true
+	}
+	return false
+}
+
+// gorecover stops the current panic, if any, and returns its value. The
+// panic is only stopped if the caller of recover was called directly by a
+// deferred call, as reported by canrecover on entry to the caller.
+func gorecover(direct bool) (value, typ unsafe.Pointer) {
+	p := getg().panicking
+	if direct && p != nil && !p.recovered {
+		p.recovered = true
+		return p.value, p.typ
+	}
+	return nil, nil
+}
+
+// panicrecovered is called by a landing pad after running the frame's
+// deferred calls. If one of them recovered the panic, then the panic is
+// finished and panicrecovered returns true.
+func panicrecovered() bool {
+	g := getg()
+	p := g.panicking
+	if p != nil && p.recovered {
+		g.panicking = p.link
+		return true
+	}
+	return false
+}
+
+// assertfailed panics with the error for a failed type assertion x.(T),
+// where iface is the static type of x, have is the dynamic type of x (or
+// nil if x is nil), and want is the type T.
+func assertfailed(iface, have, want unsafe.Pointer) {
+	inter := typestring((*commonType)(iface))
+	wantstr := typestring((*commonType)(want))
+	if have == nil {
+		panic("interface conversion: " + inter + " is nil, not " + wantstr)
+	}
+	havestr := typestring((*commonType)(have))
+	if (*commonType)(want).kind == kindInterface {
+		panic("interface conversion: " + havestr + " is not " + wantstr +
+			": missing method " + missingmethod(have, want))
+	}
+	panic("interface conversion: " + inter + " is " + havestr + ", not " + wantstr)
+}
+
+// panicdivide panics with the error for an integer division by zero.
+func panicdivide() {
+	panic("runtime error: integer divide by zero")
+}
+
+// panicnil panics with the error for a nil pointer dereference.
+func panicnil() {
+	panic("runtime error: invalid memory address or nil pointer dereference")
+}
+
+// typestring returns the string representation of a type, as recorded in
+// its runtime type.
+func typestring(t *commonType) string {
+	if t.string != nil {
+		return *t.string
+	}
+	return ""
+}
+
+// panicexit prints the value of an unrecovered panic and exits.
+func panicexit() {
+	p := getg().panicking
+	if p.typ == nil {
+		println("panic: nil")
+		exit(2)
+	}
+
+	t := (*commonType)(p.typ)
+	k := t.kind
+	if k == kindBool {
+		println("panic:", uintptr(p.value) != 0)
+	} else if k == kindInt || k == kindInt64 {
+		println("panic:", int(uintptr(p.value)))
+	} else if k == kindInt8 {
+		println("panic:", int(int8(uintptr(p.value))))
+	} else if k == kindInt16 {
+		println("panic:", int(int16(uintptr(p.value))))
+	} else if k == kindInt32 {
+		println("panic:", int(int32(uintptr(p.value))))
+	} else if k >= kindUint && k <= kindUintptr {
+		println("panic:", uint(uintptr(p.value)))
+	} else if k == kindString {
+		println("panic:", *(*string)(p.value))
+	} else {
+		// TODO print the value.
+		println("panic: ("+typestring(t)+")", uintptr(p.value))
+	}
+	exit(2)
+}
+
+// vim: set ft=go:
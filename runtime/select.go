@@ -0,0 +1,195 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// _selectcase describes a single communication clause of a select
+// statement. The layout must match the one generated by the compiler.
+type _selectcase struct {
+	ch   unsafe.Pointer
+	elem unsafe.Pointer
+	send bool
+	ok   bool // set for the chosen receive case
+}
+
+// _selwaiter is a select statement blocked with one or more receive
+// cases. A send in another select statement may hand its value off to a
+// waiting select statement by claiming it, after which no other sender may
+// claim it.
+type _selwaiter struct {
+	claimed *_chan // the channel holding the value handed off, if any
+}
+
+// _selnode is an entry in a channel's list of waiting select statements.
+type _selnode struct {
+	w    *_selwaiter
+	next *_selnode
+}
+
+// selectseed is the state of a linear congruential generator, used to
+// choose uniformly between ready cases.
+var selectseed uint = 1
+
+// selwaiter returns a select statement waiting to receive from a channel
+// which has not been claimed by a sender, or nil if there is none.
+func selwaiter(c *_chan) *_selwaiter {
+	for n := c.selwaiters; n != nil; n = n.next {
+		if n.w.claimed == nil {
+			return n.w
+		}
+	}
+	return nil
+}
+
+func selectready(sc *_selectcase) bool {
+	c := (*_chan)(sc.ch)
+	if c == nil {
+		return false
+	}
+	if c.closed {
+		return true
+	}
+	if sc.send {
+		if c.cap == 0 {
+			return c.len == 0 && (c.recvwaiting > 0 || selwaiter(c) != nil)
+		}
+		return c.len < c.cap
+	}
+	return c.len > 0
+}
+
+// selectwaiting registers (or unregisters, if register is false) the
+// waiting select statement w with the channels of each of its receive
+// cases.
+func selectwaiting(cases []_selectcase, w *_selwaiter, register bool) {
+	for i := 0; i < len(cases); i++ {
+		c := (*_chan)(cases[i].ch)
+		if c == nil || cases[i].send {
+			continue
+		}
+		if register {
+			n := new(_selnode)
+			n.w = w
+			n.next = c.selwaiters
+			c.selwaiters = n
+		} else {
+			p := &c.selwaiters
+			for *p != nil {
+				if (*p).w == w {
+					*p = (*p).next
+				} else {
+					p = &(*p).next
+				}
+			}
+		}
+	}
+	chansignal()
+}
+
+// selectclaimed returns the index of the receive case on the channel
+// whose value was handed off to the waiting select statement w, or -1 if
+// w has not been claimed. If the value has since been taken by another
+// receiver, the claim is released.
+func selectclaimed(cases []_selectcase, w *_selwaiter) int {
+	if w.claimed == nil {
+		return -1
+	}
+	for i := 0; i < len(cases); i++ {
+		sc := &cases[i]
+		if !sc.send && (*_chan)(sc.ch) == w.claimed && selectready(sc) {
+			return i
+		}
+	}
+	w.claimed = nil
+	return -1
+}
+
+// selectgo chooses one of the ready cases pseudo-randomly, performs its
+// communication and returns its index. If no case is ready, selectgo
+// returns -1 if there is a default clause, or otherwise blocks until a case
+// becomes ready.
+func selectgo(cases []_selectcase, hasdefault bool) int {
+	chanlock()
+	chosen := -1
+	var w *_selwaiter
+	for chosen == -1 {
+		if w != nil {
+			chosen = selectclaimed(cases, w)
+			if chosen != -1 {
+				break
+			}
+		}
+
+		nready := 0
+		for i := 0; i < len(cases); i++ {
+			if selectready(&cases[i]) {
+				nready++
+			}
+		}
+
+		if nready > 0 {
+			selectseed = selectseed*1103515245 + 12345
+			k := selectseed / 65536
+			k = k - (k/uint(nready))*uint(nready)
+			for i := 0; chosen == -1; i++ {
+				if selectready(&cases[i]) {
+					if k == 0 {
+						chosen = i
+					}
+					k--
+				}
+			}
+		} else if hasdefault {
+			chanunlock()
+			return -1
+		} else {
+			if w == nil {
+				w = new(_selwaiter)
+				selectwaiting(cases, w, true)
+			}
+			chanwait()
+		}
+	}
+	if w != nil {
+		selectwaiting(cases, w, false)
+	}
+
+	sc := &cases[chosen]
+	c := (*_chan)(sc.ch)
+	if sc.send {
+		// If no receiver is blocked on an unbuffered channel, then the
+		// case was ready because a select statement is waiting: hand the
+		// value off to it, so that no other sender can also commit to it.
+		if c.cap == 0 && !c.closed && c.recvwaiting == 0 {
+			selwaiter(c).claimed = c
+		}
+		chansendlocked(c, sc.elem)
+	} else {
+		sc.ok = chanrecvlocked(c, sc.elem)
+	}
+	chanunlock()
+	return chosen
+}
+
+// vim: set ft=go:
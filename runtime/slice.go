@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// slice mirrors the representation of slices generated by the compiler.
+type slice struct {
+	array unsafe.Pointer
+	len   int32
+	cap   int32
+}
+
+func memmove(dst, src unsafe.Pointer, size int)
+
+// maxslicelen is the maximum length or capacity of a slice, which is
+// limited by the 32-bit length and capacity fields.
+const maxslicelen = 0x7fffffff
+
+// slicemake creates a slice with a zeroed array of capacity elements of the
+// given size, of which the first length are in the slice. A negative
+// length or capacity converts to a huge uintptr, so one comparison checks
+// each bound.
+func slicemake(length, capacity, elemsize int) slice {
+	if uintptr(length) > maxslicelen {
+		panic("makeslice: len out of range")
+	}
+	if uintptr(capacity) > maxslicelen || capacity < length {
+		panic("makeslice: cap out of range")
+	}
+	var s slice
+	size := capacity * elemsize
+	s.array = malloc(size)
+	memset(s.array, 0, size)
+	s.len = int32(length)
+	s.cap = int32(capacity)
+	return s
+}
+
+// sliceappend appends n elements of the given size, stored at elems, to
+// the slice s, reallocating the slice's array if its capacity is exceeded.
+// The capacity of a reallocated array is doubled, so that repeated appends
+// take amortised constant time.
+func sliceappend(s slice, elems unsafe.Pointer, n, elemsize int) slice {
+	if n == 0 {
+		return s
+	}
+	length := int(s.len)
+	newlen := length + n
+	if newlen > int(s.cap) {
+		newcap := int(s.cap) * 2
+		if newcap < newlen {
+			newcap = newlen
+		}
+		array := malloc(newcap * elemsize)
+		memcpy(array, s.array, length*elemsize)
+		s.array = array
+		s.cap = int32(newcap)
+	}
+	// The elements may overlap with the slice's array.
+	dst := unsafe.Pointer(uintptr(s.array) + uintptr(length*elemsize))
+	memmove(dst, elems, n*elemsize)
+	s.len = int32(newlen)
+	return s
+}
+
+// sliceslice returns s[low:high:max] for a slice of elements of the given
+// size, panicking if the indices are not in the range 0 <= low <= high <=
+// max <= cap(s). The indices are compared as uintptrs, so negative indices
+// are out of range.
+func sliceslice(s slice, low, high, max, elemsize int) slice {
+	if uintptr(max) > uintptr(s.cap) || uintptr(high) > uintptr(max) || uintptr(low) > uintptr(high) {
+		panic("slice bounds out of range")
+	}
+	s.array = unsafe.Pointer(uintptr(s.array) + uintptr(low*elemsize))
+	s.len = int32(high - low)
+	s.cap = int32(max - low)
+	return s
+}
+
+// slicecopy copies elements of the given size from src to dst, which may
+// overlap, and returns the number of elements copied: the minimum of the
+// slices' lengths.
+func slicecopy(dst, src slice, elemsize int) int {
+	n := int(dst.len)
+	if int(src.len) < n {
+		n = int(src.len)
+	}
+	memmove(dst.array, src.array, n*elemsize)
+	return n
+}
+
+// vim: set ft=go:
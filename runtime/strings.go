@@ -26,9 +26,11 @@ import "unsafe"
 
 type str struct {
 	ptr *uint8
-	size int
+	size int32
 }
 
+const runeError = 0xFFFD
+
 func malloc(int) unsafe.Pointer
 func memcpy(dst, src unsafe.Pointer, size int)
 
@@ -39,14 +41,14 @@ func strcat(a, b str) str {
 		return a
 	}
 
-	mem := malloc(a.size + b.size)
+	mem := malloc(int(a.size + b.size))
 	if mem == unsafe.Pointer(uintptr(0)) {
 		// TODO panic? abort?
 	}
 
-	memcpy(mem, unsafe.Pointer(a.ptr), a.size)
+	memcpy(mem, unsafe.Pointer(a.ptr), int(a.size))
 	memcpy(unsafe.Pointer(uintptr(mem) + uintptr(a.size)),
-		   unsafe.Pointer(b.ptr), b.size)
+		   unsafe.Pointer(b.ptr), int(b.size))
 
 	a.ptr = (*uint8)(mem)
 	a.size = a.size + b.size
@@ -59,7 +61,7 @@ func strcmp(a, b str) int32 {
 		sz = b.size
 	}
 	aptr, bptr := a.ptr, b.ptr
-	for i := 0; i < sz; i++ {
+	for i := int32(0); i < sz; i++ {
 		c1, c2 := *aptr, *bptr
 		switch {
 		case c1 < c2:
@@ -79,5 +81,200 @@ func strcmp(a, b str) int32 {
 	return 0
 }
 
+func strbyte(s str, i int) uint8 {
+	return *(*uint8)(unsafe.Pointer(uintptr(unsafe.Pointer(s.ptr)) + uintptr(i)))
+}
+
+// strindex returns the byte at index i of s, panicking if i is out of
+// range. The index is compared as a uintptr, so negative indices are out of
+// range.
+func strindex(s str, i int) uint8 {
+	if uintptr(i) >= uintptr(s.size) {
+		panic("index out of range")
+	}
+	return strbyte(s, i)
+}
+
+// strnext decodes the UTF-8 encoded rune starting at byte index i of s,
+// returning the rune and the index of the following rune. Invalid
+// encodings decode as U+FFFD, consuming a single byte.
+func strnext(s str, i int) (int32, int) {
+	c0 := strbyte(s, i)
+	if c0 < 0x80 {
+		return int32(c0), i + 1
+	}
+	n := int(s.size) - i
+	if c0 < 0xC2 || c0 > 0xF4 || n < 2 {
+		return runeError, i + 1
+	}
+
+	c1 := strbyte(s, i+1)
+	if c1&0xC0 != 0x80 {
+		return runeError, i + 1
+	}
+	if c0 < 0xE0 {
+		return int32(c0&0x1F)<<6 | int32(c1&0x3F), i + 2
+	}
+
+	if n < 3 {
+		return runeError, i + 1
+	}
+	c2 := strbyte(s, i+2)
+	if c2&0xC0 != 0x80 {
+		return runeError, i + 1
+	}
+	if c0 < 0xF0 {
+		r := int32(c0&0x0F)<<12 | int32(c1&0x3F)<<6 | int32(c2&0x3F)
+		if r < 0x800 || (r >= 0xD800 && r <= 0xDFFF) {
+			return runeError, i + 1
+		}
+		return r, i + 3
+	}
+
+	if n < 4 {
+		return runeError, i + 1
+	}
+	c3 := strbyte(s, i+3)
+	if c3&0xC0 != 0x80 {
+		return runeError, i + 1
+	}
+	r := int32(c0&0x07)<<18 | int32(c1&0x3F)<<12 | int32(c2&0x3F)<<6 | int32(c3&0x3F)
+	if r < 0x10000 || r > 0x10FFFF {
+		return runeError, i + 1
+	}
+	return r, i + 4
+}
+
+// runelen returns the number of bytes required to encode r in UTF-8.
+// Invalid runes are encoded as U+FFFD, which requires three bytes.
+func runelen(r int32) int {
+	u := uint32(r)
+	switch {
+	case u < 0x80:
+		return 1
+	case u < 0x800:
+		return 2
+	case u < 0x10000:
+		return 3
+	case u <= 0x10FFFF:
+		return 4
+	}
+	return 3
+}
+
+func setbyte(p unsafe.Pointer, i int, b uint8) {
+	*(*uint8)(unsafe.Pointer(uintptr(p) + uintptr(i))) = b
+}
+
+// encoderune writes the UTF-8 encoding of r to p, returning the number of
+// bytes written. Surrogate halves and runes greater than U+10FFFF are
+// encoded as U+FFFD.
+func encoderune(p unsafe.Pointer, r int32) int {
+	u := uint32(r)
+	if (u >= 0xD800 && u <= 0xDFFF) || u > 0x10FFFF {
+		u = runeError
+	}
+	switch {
+	case u < 0x80:
+		setbyte(p, 0, uint8(u))
+		return 1
+	case u < 0x800:
+		setbyte(p, 0, uint8(0xC0|u>>6))
+		setbyte(p, 1, uint8(0x80|u&0x3F))
+		return 2
+	case u < 0x10000:
+		setbyte(p, 0, uint8(0xE0|u>>12))
+		setbyte(p, 1, uint8(0x80|(u>>6)&0x3F))
+		setbyte(p, 2, uint8(0x80|u&0x3F))
+		return 3
+	}
+	setbyte(p, 0, uint8(0xF0|u>>18))
+	setbyte(p, 1, uint8(0x80|(u>>12)&0x3F))
+	setbyte(p, 2, uint8(0x80|(u>>6)&0x3F))
+	setbyte(p, 3, uint8(0x80|u&0x3F))
+	return 4
+}
+
+// strfrombytes returns a string containing a copy of the bytes in b.
+func strfrombytes(b slice) str {
+	var s str
+	if b.len == 0 {
+		return s
+	}
+	mem := malloc(int(b.len))
+	memcpy(mem, b.array, int(b.len))
+	s.ptr = (*uint8)(mem)
+	s.size = b.len
+	return s
+}
+
+// strtobytes returns a byte slice containing a copy of the bytes in s.
+func strtobytes(s str) slice {
+	var b slice
+	b.array = malloc(int(s.size))
+	memcpy(b.array, unsafe.Pointer(s.ptr), int(s.size))
+	b.len = s.size
+	b.cap = s.size
+	return b
+}
+
+// strfromrune returns the UTF-8 encoding of the integer r as a string.
+// Integers that are not valid runes are converted to "\uFFFD".
+func strfromrune(r int64) str {
+	if uint64(r) > 0x10FFFF {
+		r = runeError
+	}
+	var s str
+	mem := malloc(4)
+	s.ptr = (*uint8)(mem)
+	s.size = int32(encoderune(mem, int32(r)))
+	return s
+}
+
+func runeat(runes slice, i int) int32 {
+	return *(*int32)(unsafe.Pointer(uintptr(runes.array) + uintptr(i*4)))
+}
+
+// strfromrunes returns the concatenation of the UTF-8 encodings of the
+// runes in a rune slice.
+func strfromrunes(runes slice) str {
+	var s str
+	n := 0
+	for i := 0; i < int(runes.len); i++ {
+		n = n + runelen(runeat(runes, i))
+	}
+	if n == 0 {
+		return s
+	}
+	mem := malloc(n)
+	p := 0
+	for i := 0; i < int(runes.len); i++ {
+		p = p + encoderune(unsafe.Pointer(uintptr(mem)+uintptr(p)), runeat(runes, i))
+	}
+	s.ptr = (*uint8)(mem)
+	s.size = int32(n)
+	return s
+}
+
+// strtorunes returns a rune slice containing the runes decoded from s, as
+// by a range statement.
+func strtorunes(s str) slice {
+	n := 0
+	for i := 0; i < int(s.size); n++ {
+		_, i = strnext(s, i)
+	}
+	var runes slice
+	runes.array = malloc(n * 4)
+	runes.len = int32(n)
+	runes.cap = int32(n)
+	j := 0
+	for i := 0; i < int(s.size); j++ {
+		var r int32
+		r, i = strnext(s, i)
+		*(*int32)(unsafe.Pointer(uintptr(runes.array) + uintptr(j*4))) = r
+	}
+	return runes
+}
+
 // vim: set ft=go:
 
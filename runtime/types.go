@@ -0,0 +1,221 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package runtime
+
+import "unsafe"
+
+// The following types mirror the runtime type descriptors in package
+// reflect, which the compiler generates for each type stored in an
+// interface value.
+
+// commonType is the header of every runtime type descriptor.
+type commonType struct {
+	size       uintptr
+	hash       uint32
+	pad        uint8
+	align      uint8
+	fieldAlign uint8
+	kind       uint8
+	alg        *typeAlg
+	string     *string
+	uncommon   *uncommonType
+	ptrToThis  unsafe.Pointer
+}
+
+// Values of commonType.kind; see reflect.Kind.
+const (
+	kindBool      = 1
+	kindInt       = 2
+	kindInt8      = 3
+	kindInt16     = 4
+	kindInt32     = 5
+	kindInt64     = 6
+	kindUint      = 7
+	kindUint8     = 8
+	kindUint16    = 9
+	kindUint32    = 10
+	kindUint64    = 11
+	kindUintptr   = 12
+	kindInterface = 20
+	kindString    = 24
+)
+
+// uncommonType holds the name and methods of named types, and of
+// pointers to named types.
+type uncommonType struct {
+	name    *string
+	pkgPath *string
+	methods []method // sorted by name
+}
+
+type method struct {
+	name    *string
+	pkgPath *string
+	mtyp    unsafe.Pointer
+	typ     unsafe.Pointer
+	ifn     unsafe.Pointer // function used in interface calls
+	tfn     unsafe.Pointer
+}
+
+type interfaceType struct {
+	commonType
+	methods []imethod // sorted by name
+}
+
+type imethod struct {
+	name    *string
+	pkgPath *string
+	typ     unsafe.Pointer
+}
+
+// itab is the header of the method table of a non-empty interface value,
+// which is followed by the functions implementing the interface's methods.
+// The compiler creates itabs for conversions of values with statically
+// known types, and getitab creates them for dynamic conversions. A value
+// of an interface whose methods are the first methods of inter may share
+// an itab created for inter.
+type itab struct {
+	typ   unsafe.Pointer // dynamic type
+	inter unsafe.Pointer // interface type
+	link  *itab          // next itab in the same bucket of itabs
+}
+
+// itabs is a hash table of the itabs created by getitab, with
+// itabbuckets buckets. It is allocated on first use, and guarded by
+// itablock and itabunlock.
+var itabs unsafe.Pointer // [itabbuckets]*itab
+
+const itabbuckets = 1009
+
+func itablock()
+func itabunlock()
+
+// itabbucket returns a pointer to the bucket of itabs for the dynamic type
+// typ and interface type iface.
+func itabbucket(typ, iface unsafe.Pointer) **itab {
+	h := (uintptr(typ) >> 3) ^ ((uintptr(iface) >> 3) * 31)
+	h = h % itabbuckets
+	return (**itab)(unsafe.Pointer(uintptr(itabs) + h*ptrsize()))
+}
+
+// getitab returns the itab for the interface type iface and the dynamic
+// type typ, looking up each of the interface's methods in the method table
+// of typ. If typ is nil, or does not implement iface, then getitab returns
+// nil.
+func getitab(typ, iface unsafe.Pointer) unsafe.Pointer {
+	if typ == nil {
+		return nil
+	}
+	itablock()
+	if itabs == nil {
+		size := int(itabbuckets * ptrsize())
+		itabs = malloc(size)
+		memset(itabs, 0, size)
+	}
+	bucket := itabbucket(typ, iface)
+	for m := *bucket; m != nil; m = m.link {
+		if m.typ == typ && m.inter == iface {
+			itabunlock()
+			return unsafe.Pointer(m)
+		}
+	}
+	if missingmethod(typ, iface) != "" {
+		itabunlock()
+		return nil
+	}
+
+	// Both method lists are sorted by name, so we need only make one
+	// pass through the type's methods.
+	it := (*interfaceType)(iface)
+	u := (*commonType)(typ).uncommon
+	n := uintptr(len(it.methods))
+	m := (*itab)(malloc(int((3 + n) * ptrsize())))
+	m.typ = typ
+	m.inter = iface
+	fns := uintptr(unsafe.Pointer(m)) + 3*ptrsize()
+	j := 0
+	for i := 0; i < len(it.methods); i++ {
+		name := *it.methods[i].name
+		for *u.methods[j].name != name {
+			j++
+		}
+		fn := (*unsafe.Pointer)(unsafe.Pointer(fns + uintptr(i)*ptrsize()))
+		*fn = u.methods[j].ifn
+	}
+	m.link = *bucket
+	*bucket = m
+	itabunlock()
+	return unsafe.Pointer(m)
+}
+
+// missingmethod returns the name of the first method of the interface type
+// iface which is not in the method table of the dynamic type typ, or whose
+// type or package differs from the interface's method.
+func missingmethod(typ, iface unsafe.Pointer) string {
+	it := (*interfaceType)(iface)
+	u := (*commonType)(typ).uncommon
+	j := 0
+	for i := 0; i < len(it.methods); i++ {
+		name := *it.methods[i].name
+		if u == nil {
+			return name
+		}
+		for j < len(u.methods) && *u.methods[j].name != name {
+			j++
+		}
+		if j == len(u.methods) || !methodmatch(&it.methods[i], &u.methods[j]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// methodmatch reports whether the method m of a dynamic type implements
+// the interface method im. The methods must have the same name and type,
+// and unexported names must be from the same package.
+func methodmatch(im *imethod, m *method) bool {
+	if *im.name != *m.name || !typesequal(im.typ, m.mtyp) {
+		return false
+	}
+	if im.pkgPath == m.pkgPath {
+		return true
+	}
+	return im.pkgPath != nil && m.pkgPath != nil && *im.pkgPath == *m.pkgPath
+}
+
+// typesequal reports whether t1 and t2 are runtime types of identical
+// types. Each module has its own runtime types, so runtime types at
+// different addresses are compared by their hash and string.
+func typesequal(t1, t2 unsafe.Pointer) bool {
+	if t1 == t2 {
+		return true
+	}
+	if t1 == nil || t2 == nil {
+		return false
+	}
+	c1, c2 := (*commonType)(t1), (*commonType)(t2)
+	return c1.hash == c2.hash && typestring(c1) == typestring(c2)
+}
+
+// vim: set ft=go:
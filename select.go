@@ -0,0 +1,188 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/token"
+)
+
+// selectCaseType returns the LLVM type of the runtime's _selectcase
+// structure: {ch, elem, send, ok}.
+func (c *compiler) selectCaseType() llvm.Type {
+	ptrType := c.target.IntPtrType()
+	fieldTypes := []llvm.Type{ptrType, ptrType, llvm.Int1Type(), llvm.Int1Type()}
+	return llvm.StructType(fieldTypes, false)
+}
+
+// VisitSelectStmt lowers a select statement to a call to runtime.selectgo,
+// which chooses one of the ready communication clauses (or the default
+// clause) and performs its communication. The index of the chosen clause
+// is then used to branch to the clause's body.
+func (c *compiler) VisitSelectStmt(stmt *ast.SelectStmt) {
+	// Separate the default clause from the communication clauses.
+	var defaultClause *ast.CommClause
+	clauses := make([]*ast.CommClause, 0, len(stmt.Body.List))
+	for _, stmt := range stmt.Body.List {
+		clause := stmt.(*ast.CommClause)
+		if clause.Comm == nil {
+			defaultClause = clause
+		} else {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	// Evaluate the channel operands and send values, in source order, and
+	// fill in the case records. The records and values are stored in the
+	// entry block, so that they are reused if the select is in a loop.
+	ptrType := c.target.IntPtrType()
+	caseType := c.selectCaseType()
+	n := llvm.ConstInt(llvm.Int32Type(), uint64(len(clauses)), false)
+	cases := c.entryAlloca(llvm.ArrayType(caseType, len(clauses)), "")
+	cases = c.builder.CreateBitCast(cases, llvm.PointerType(caseType, 0), "")
+	elemptrs := make([]llvm.Value, len(clauses))
+	elttyps := make([]types.Type, len(clauses))
+	for i, clause := range clauses {
+		var ch *LLVMValue
+		var send Value
+		switch comm := clause.Comm.(type) {
+		case *ast.SendStmt:
+			ch = c.VisitExpr(comm.Chan).(*LLVMValue)
+			send = c.VisitExpr(comm.Value)
+		case *ast.ExprStmt:
+			recv := unparen(comm.X).(*ast.UnaryExpr)
+			ch = c.VisitExpr(recv.X).(*LLVMValue)
+		case *ast.AssignStmt:
+			recv := unparen(comm.Rhs[0]).(*ast.UnaryExpr)
+			ch = c.VisitExpr(recv.X).(*LLVMValue)
+		}
+
+		elttyp := types.Underlying(ch.Type()).(*types.Chan).Elt
+		elttyps[i] = elttyp
+		elemptrs[i] = c.entryAlloca(c.types.ToLLVM(elttyp), "")
+		if send != nil {
+			c.builder.CreateStore(send.Convert(elttyp).LLVMValue(), elemptrs[i])
+		}
+
+		indices := []llvm.Value{llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}
+		caseptr := c.builder.CreateGEP(cases, indices, "")
+		c.builder.CreateStore(llvm.ConstNull(caseType), caseptr)
+		chint := c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")
+		elemint := c.builder.CreatePtrToInt(elemptrs[i], ptrType, "")
+		issend := llvm.ConstInt(llvm.Int1Type(), 0, false)
+		if send != nil {
+			issend = llvm.ConstAllOnes(llvm.Int1Type())
+		}
+		c.builder.CreateStore(chint, c.builder.CreateStructGEP(caseptr, 0, ""))
+		c.builder.CreateStore(elemint, c.builder.CreateStructGEP(caseptr, 1, ""))
+		c.builder.CreateStore(issend, c.builder.CreateStructGEP(caseptr, 2, ""))
+	}
+
+	// Call runtime.selectgo with a slice of the case records.
+	sliceType := llvm.StructType([]llvm.Type{
+		llvm.PointerType(caseType, 0), llvm.Int32Type(), llvm.Int32Type()}, false)
+	paramTypes := []llvm.Type{sliceType, llvm.Int1Type()}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	selectgo := c.namedFunction("runtime.selectgo", funcType)
+	slice := llvm.Undef(sliceType)
+	slice = c.builder.CreateInsertValue(slice, cases, 0, "")
+	slice = c.builder.CreateInsertValue(slice, n, 1, "")
+	slice = c.builder.CreateInsertValue(slice, n, 2, "")
+	hasdefault := llvm.ConstInt(llvm.Int1Type(), 0, false)
+	if defaultClause != nil {
+		hasdefault = llvm.ConstAllOnes(llvm.Int1Type())
+	}
+	args := []llvm.Value{slice, hasdefault}
+	chosen := c.createCall(selectgo, args)
+
+	// Branch to the chosen clause. If the default clause is chosen, then
+	// selectgo returns -1, which will not match any of the switch cases.
+	startBlock := c.builder.GetInsertBlock()
+	endBlock := llvm.AddBasicBlock(startBlock.Parent(), "end")
+	endBlock.MoveAfter(startBlock)
+	defaultBlock := endBlock
+	if defaultClause != nil {
+		defaultBlock = llvm.InsertBasicBlock(endBlock, "")
+	}
+	caseBlocks := make([]llvm.BasicBlock, len(clauses))
+	for i := range clauses {
+		caseBlocks[i] = llvm.InsertBasicBlock(endBlock, "")
+	}
+	switch_ := c.builder.CreateSwitch(chosen, defaultBlock, len(clauses))
+	for i, block := range caseBlocks {
+		switch_.AddCase(llvm.ConstInt(ptrType, uint64(i), false), block)
+	}
+
+	c.pushBranchTarget(endBlock, llvm.BasicBlock{})
+	defer c.popBranchTarget()
+	if defaultClause != nil {
+		c.builder.SetInsertPointAtEnd(defaultBlock)
+		c.visitCommClauseBody(defaultClause, endBlock)
+	}
+	for i, clause := range clauses {
+		c.builder.SetInsertPointAtEnd(caseBlocks[i])
+		c.PushScope()
+		if assign, ok := clause.Comm.(*ast.AssignStmt); ok {
+			// v[, ok] = <-ch
+			values := make([]Value, len(assign.Lhs))
+			elem := c.builder.CreateLoad(elemptrs[i], "")
+			values[0] = c.NewLLVMValue(elem, elttyps[i])
+			if len(values) > 1 {
+				indices := []llvm.Value{llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}
+				caseptr := c.builder.CreateGEP(cases, indices, "")
+				ok := c.builder.CreateLoad(c.builder.CreateStructGEP(caseptr, 3, ""), "")
+				values[1] = c.NewLLVMValue(ok, types.Bool)
+			}
+			c.assign(assign.Lhs, values, assign.Tok == token.DEFINE)
+		}
+		c.visitCommClauseBody(clause, endBlock)
+		c.PopScope()
+	}
+	c.builder.SetInsertPointAtEnd(endBlock)
+}
+
+// unparen returns x with any enclosing parentheses removed.
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		paren, ok := x.(*ast.ParenExpr)
+		if !ok {
+			return x
+		}
+		x = paren.X
+	}
+	panic("unreachable")
+}
+
+// visitCommClauseBody compiles the body of a select statement's clause,
+// branching to endBlock if the body does not terminate.
+func (c *compiler) visitCommClauseBody(clause *ast.CommClause, endBlock llvm.BasicBlock) {
+	for _, stmt := range clause.Body {
+		c.VisitStmt(stmt)
+	}
+	c.maybeBranch(endBlock)
+}
+
+// vim: set ft=go :
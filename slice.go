@@ -23,26 +23,252 @@ SOFTWARE.
 package llgo
 
 import (
-	//"go/ast"
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
+	"go/ast"
 )
 
-func (c *compiler) makeSlice(v []llvm.Value, elttyp types.Type) llvm.Value {
-	n := llvm.ConstInt(llvm.Int32Type(), uint64(len(v)), false)
-	mem := c.builder.CreateArrayMalloc(c.types.ToLLVM(elttyp), n, "")
+// makeSlice creates a new slice of the specified type, with a zeroed array
+// of capacity elements, the first length of which are in the slice. If
+// capacity is nil, then it is the same as length.
+func (c *compiler) makeSlice(typ types.Type, length, capacity Value) *LLVMValue {
+	elttyp := types.Underlying(typ).(*types.Slice).Elt
+	ptrType := c.target.IntPtrType()
+	runtimeSliceType := c.runtimeSliceType()
+	paramTypes := []llvm.Type{ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(runtimeSliceType, paramTypes, false)
+	slicemake := c.namedFunction("runtime.slicemake", funcType)
+
+	length = length.Convert(types.Int)
+	if capacity == nil {
+		capacity = length
+	} else {
+		capacity = capacity.Convert(types.Int)
+	}
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(elttyp))
+	args := []llvm.Value{
+		length.LLVMValue(), capacity.LLVMValue(),
+		llvm.ConstInt(ptrType, elemsize, false)}
+	result := c.createCall(slicemake, args)
+	return c.fromRuntimeSlice(result, typ)
+}
+
+// makeLiteralSlice creates a new slice of the specified type, containing
+// the given element values.
+func (c *compiler) makeLiteralSlice(v []llvm.Value, typ types.Type) *LLVMValue {
+	n := llvm.ConstInt(c.target.IntPtrType(), uint64(len(v)), false)
+	slice := c.makeSlice(typ, c.NewLLVMValue(n, types.Int), nil)
+	mem := c.builder.CreateExtractValue(slice.LLVMValue(), 0, "")
 	for i, value := range v {
 		indices := []llvm.Value{
 			llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}
 		ep := c.builder.CreateGEP(mem, indices, "")
 		c.builder.CreateStore(value, ep)
 	}
-	slicetyp := types.Slice{Elt: elttyp}
-	struct_ := c.builder.CreateAlloca(c.types.ToLLVM(&slicetyp), "")
-	c.builder.CreateStore(mem, c.builder.CreateStructGEP(struct_, 0, ""))
-	c.builder.CreateStore(n, c.builder.CreateStructGEP(struct_, 1, ""))
-	c.builder.CreateStore(n, c.builder.CreateStructGEP(struct_, 2, ""))
-	return c.builder.CreateLoad(struct_, "")
+	return slice
+}
+
+// runtimeSliceType returns the LLVM type of the runtime's slice structure,
+// which is used to pass slices of any element type to the runtime.
+func (c *compiler) runtimeSliceType() llvm.Type {
+	ptrType := c.target.IntPtrType()
+	fieldTypes := []llvm.Type{ptrType, llvm.Int32Type(), llvm.Int32Type()}
+	return llvm.StructType(fieldTypes, false)
+}
+
+// toRuntimeSlice converts a slice or string value to the runtime's slice
+// structure. Strings are treated as byte slices whose capacity is their
+// length.
+func (c *compiler) toRuntimeSlice(v Value) llvm.Value {
+	value := v.LLVMValue()
+	ptr := c.builder.CreateExtractValue(value, 0, "")
+	ptr = c.builder.CreatePtrToInt(ptr, c.target.IntPtrType(), "")
+	length := c.builder.CreateExtractValue(value, 1, "")
+	capacity := length
+	if !isStringType(v.Type()) {
+		capacity = c.builder.CreateExtractValue(value, 2, "")
+	}
+	result := llvm.Undef(c.runtimeSliceType())
+	result = c.builder.CreateInsertValue(result, ptr, 0, "")
+	result = c.builder.CreateInsertValue(result, length, 1, "")
+	result = c.builder.CreateInsertValue(result, capacity, 2, "")
+	return result
+}
+
+// fromRuntimeSlice converts a runtime slice structure to a slice or string
+// of the specified type. The capacity is dropped for strings.
+func (c *compiler) fromRuntimeSlice(v llvm.Value, typ types.Type) *LLVMValue {
+	slicetyp := c.types.ToLLVM(typ)
+	ptrtyp := slicetyp.StructElementTypes()[0]
+	ptr := c.builder.CreateExtractValue(v, 0, "")
+	ptr = c.builder.CreateIntToPtr(ptr, ptrtyp, "")
+	result := llvm.Undef(slicetyp)
+	result = c.builder.CreateInsertValue(result, ptr, 0, "")
+	result = c.builder.CreateInsertValue(result, c.builder.CreateExtractValue(v, 1, ""), 1, "")
+	if !isStringType(typ) {
+		result = c.builder.CreateInsertValue(result, c.builder.CreateExtractValue(v, 2, ""), 2, "")
+	}
+	return c.NewLLVMValue(result, typ)
+}
+
+// VisitSliceExpr compiles a slice expression on an array, pointer to array,
+// slice or string. The operand is converted to a runtime slice structure,
+// and runtime.sliceslice checks the bounds and computes the result.
+func (c *compiler) VisitSliceExpr(expr *ast.SliceExpr) Value {
+	value := c.VisitExpr(expr.X)
+	ptrType := c.target.IntPtrType()
+
+	var typ types.Type
+	var runtimeSlice llvm.Value
+	switch x := types.Underlying(value.Type()).(type) {
+	case *types.Array:
+		// Arrays must be addressable, so that the result refers to the
+		// array itself rather than a copy; the type checker rejects
+		// unaddressable arrays.
+		typ = &types.Slice{Elt: x.Elt}
+		ptr := value.(*LLVMValue).pointer.LLVMValue()
+		runtimeSlice = c.arrayRuntimeSlice(ptr, x)
+	case *types.Pointer:
+		array := types.Underlying(x.Base).(*types.Array)
+		typ = &types.Slice{Elt: array.Elt}
+		ptr := value.LLVMValue()
+		c.checkNil(ptr)
+		runtimeSlice = c.arrayRuntimeSlice(ptr, array)
+	default:
+		typ = value.Type()
+		runtimeSlice = c.toRuntimeSlice(value)
+	}
+
+	var elttyp types.Type = types.Byte
+	if slicetyp, ok := types.Underlying(typ).(*types.Slice); ok {
+		elttyp = slicetyp.Elt
+	}
+
+	// Missing indices default to zero, the length and the capacity.
+	index := func(expr ast.Expr, field int) llvm.Value {
+		if expr == nil {
+			v := c.builder.CreateExtractValue(runtimeSlice, field, "")
+			return c.NewLLVMValue(v, types.Int32).Convert(types.Int).LLVMValue()
+		}
+		return c.VisitExpr(expr).Convert(types.Int).LLVMValue()
+	}
+	low := llvm.ConstNull(ptrType)
+	if expr.Low != nil {
+		low = c.VisitExpr(expr.Low).Convert(types.Int).LLVMValue()
+	}
+	high := index(expr.High, 1)
+	max := index(expr.Max, 2)
+
+	runtimeSliceType := c.runtimeSliceType()
+	paramTypes := []llvm.Type{runtimeSliceType, ptrType, ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(runtimeSliceType, paramTypes, false)
+	sliceslice := c.namedFunction("runtime.sliceslice", funcType)
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(elttyp))
+	args := []llvm.Value{
+		runtimeSlice, low, high, max,
+		llvm.ConstInt(ptrType, elemsize, false)}
+	result := c.createCall(sliceslice, args)
+	return c.fromRuntimeSlice(result, typ)
+}
+
+// checkNil generates a call to runtime.panicnil if ptr is nil.
+func (c *compiler) checkNil(ptr llvm.Value) {
+	currBlock := c.builder.GetInsertBlock()
+	panicBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	contBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	panicBlock.MoveAfter(currBlock)
+	contBlock.MoveAfter(panicBlock)
+	c.builder.CreateCondBr(c.builder.CreateIsNull(ptr, ""), panicBlock, contBlock)
+
+	c.builder.SetInsertPointAtEnd(panicBlock)
+	panicnil := c.namedFunction("runtime.panicnil",
+		llvm.FunctionType(llvm.VoidType(), nil, false))
+	c.createCall(panicnil, nil)
+	c.builder.CreateUnreachable()
+	c.builder.SetInsertPointAtEnd(contBlock)
+}
+
+// arrayRuntimeSlice returns a runtime slice structure referring to the
+// whole of the array pointed to by ptr.
+func (c *compiler) arrayRuntimeSlice(ptr llvm.Value, array *types.Array) llvm.Value {
+	zero := llvm.ConstNull(llvm.Int32Type())
+	ptr = c.builder.CreateGEP(ptr, []llvm.Value{zero, zero}, "")
+	ptr = c.builder.CreatePtrToInt(ptr, c.target.IntPtrType(), "")
+	length := llvm.ConstInt(llvm.Int32Type(), array.Len, false)
+	result := llvm.Undef(c.runtimeSliceType())
+	result = c.builder.CreateInsertValue(result, ptr, 0, "")
+	result = c.builder.CreateInsertValue(result, length, 1, "")
+	result = c.builder.CreateInsertValue(result, length, 2, "")
+	return result
+}
+
+// VisitAppend compiles a call to append. The elements to append are either
+// stored in a temporary array, or taken from the slice or string passed
+// with "...", and appended by runtime.sliceappend. The temporary array is
+// allocated in the entry block, so that appends in a loop reuse it.
+func (c *compiler) VisitAppend(expr *ast.CallExpr) Value {
+	s := c.VisitExpr(expr.Args[0])
+	slicetyp := s.Type()
+	elttyp := types.Underlying(slicetyp).(*types.Slice).Elt
+	if len(expr.Args) == 1 {
+		return s
+	}
+
+	ptrType := c.target.IntPtrType()
+	var elems, n llvm.Value
+	if expr.Ellipsis.IsValid() {
+		src := c.VisitExpr(expr.Args[1])
+		if !isStringType(src.Type()) {
+			src = src.Convert(slicetyp)
+		}
+		runtimeSlice := c.toRuntimeSlice(src)
+		elems = c.builder.CreateExtractValue(runtimeSlice, 0, "")
+		n = c.builder.CreateExtractValue(runtimeSlice, 1, "")
+		n = c.builder.CreateZExt(n, ptrType, "")
+	} else {
+		args := expr.Args[1:]
+		mem := c.entryAlloca(llvm.ArrayType(c.types.ToLLVM(elttyp), len(args)), "")
+		zero := llvm.ConstNull(llvm.Int32Type())
+		for i, arg := range args {
+			value := c.VisitExpr(arg).Convert(elttyp)
+			indices := []llvm.Value{zero, llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}
+			c.builder.CreateStore(value.LLVMValue(), c.builder.CreateGEP(mem, indices, ""))
+		}
+		elems = c.builder.CreatePtrToInt(mem, ptrType, "")
+		n = llvm.ConstInt(ptrType, uint64(len(args)), false)
+	}
+
+	runtimeSliceType := c.runtimeSliceType()
+	paramTypes := []llvm.Type{runtimeSliceType, ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(runtimeSliceType, paramTypes, false)
+	sliceappend := c.namedFunction("runtime.sliceappend", funcType)
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(elttyp))
+	args := []llvm.Value{
+		c.toRuntimeSlice(s), elems, n,
+		llvm.ConstInt(ptrType, elemsize, false)}
+	result := c.createCall(sliceappend, args)
+	return c.fromRuntimeSlice(result, slicetyp)
+}
+
+// VisitCopy compiles a call to copy, which copies elements from a slice or
+// string to a slice using runtime.slicecopy, and returns the number of
+// elements copied.
+func (c *compiler) VisitCopy(expr *ast.CallExpr) Value {
+	dst := c.VisitExpr(expr.Args[0])
+	src := c.VisitExpr(expr.Args[1])
+	elttyp := types.Underlying(dst.Type()).(*types.Slice).Elt
+
+	ptrType := c.target.IntPtrType()
+	runtimeSliceType := c.runtimeSliceType()
+	paramTypes := []llvm.Type{runtimeSliceType, runtimeSliceType, ptrType}
+	funcType := llvm.FunctionType(ptrType, paramTypes, false)
+	slicecopy := c.namedFunction("runtime.slicecopy", funcType)
+	elemsize := c.target.TypeAllocSize(c.types.ToLLVM(elttyp))
+	args := []llvm.Value{
+		c.toRuntimeSlice(dst), c.toRuntimeSlice(src),
+		llvm.ConstInt(ptrType, elemsize, false)}
+	result := c.createCall(slicecopy, args)
+	return c.NewLLVMValue(result, types.Int)
 }
 
 // vim: set ft=go :
@@ -32,23 +32,11 @@ import (
 )
 
 func (c *compiler) VisitIncDecStmt(stmt *ast.IncDecStmt) {
-	ptr := c.VisitExpr(stmt.X).(*LLVMValue).pointer
-	value := c.builder.CreateLoad(ptr.LLVMValue(), "")
-	one := llvm.ConstInt(value.Type(), 1, false)
-
-	switch stmt.Tok {
-	case token.INC:
-		value = c.builder.CreateAdd(value, one, "")
-	case token.DEC:
-		value = c.builder.CreateSub(value, one, "")
-	}
-
-	// TODO make sure we cover all possibilities (maybe just delegate this to
-	// an assignment statement handler, and do it all in one place).
-	//
-	// In the case of a simple variable, we simply calculate the new value and
-	// update the value in the scope.
-	c.builder.CreateStore(value, ptr.LLVMValue())
+	op := token.ADD
+	if stmt.Tok == token.DEC {
+		op = token.SUB
+	}
+	c.assignOp(stmt.X, op, &ast.BasicLit{Kind: token.INT, Value: "1"})
 }
 
 func (c *compiler) VisitBlockStmt(stmt *ast.BlockStmt) {
@@ -60,56 +48,148 @@ func (c *compiler) VisitBlockStmt(stmt *ast.BlockStmt) {
 }
 
 func (c *compiler) VisitReturnStmt(stmt *ast.ReturnStmt) {
-	if stmt.Results == nil {
-		c.builder.CreateRetVoid()
-	} else {
-		if len(stmt.Results) == 1 {
-			value := c.VisitExpr(stmt.Results[0])
+	// Result expressions are evaluated before deferred calls are run.
+	// TODO handle multi-return value functions in result expressions.
+	cur_fn := c.functions[len(c.functions)-1]
+	fn_type := cur_fn.Type().(*types.Func)
+	values := make([]llvm.Value, len(stmt.Results))
+	for i, expr := range stmt.Results {
+		value := c.VisitExpr(expr)
+		value = value.Convert(c.ObjGetType(fn_type.Results[i]))
+		values[i] = value.LLVMValue()
+	}
 
-			// Convert value to the function's return type.
-			cur_fn := c.functions[len(c.functions)-1]
-			fn_type := cur_fn.Type().(*types.Func)
-			result := value.Convert(c.ObjGetType(fn_type.Results[0]))
+	// If the results are held in variables, then they are set before
+	// deferred calls are run, as the deferred calls may refer to them.
+	if cur_fn.results != nil {
+		for i, value := range values {
+			c.builder.CreateStore(value, cur_fn.results[i])
+		}
+		c.runDefers()
+		c.returnResults(cur_fn)
+		return
+	}
 
-			c.builder.CreateRet(result.LLVMValue())
-		} else {
-			// TODO handle multi-return value functions in
-			// result expressions.
-			// TODO convert to function's return type.
-			values := make([]llvm.Value, len(stmt.Results))
-			for i, expr := range stmt.Results {
-				values[i] = c.VisitExpr(expr).LLVMValue()
-			}
-			c.builder.CreateAggregateRet(values)
+	c.runDefers()
+	switch len(values) {
+	case 0:
+		c.builder.CreateRetVoid()
+	case 1:
+		c.builder.CreateRet(values[0])
+	default:
+		c.builder.CreateAggregateRet(values)
+	}
+}
+
+// returnResults returns from the function f with the values of the
+// variables holding its results.
+func (c *compiler) returnResults(f *function) {
+	switch len(f.results) {
+	case 0:
+		c.builder.CreateRetVoid()
+	case 1:
+		c.builder.CreateRet(c.builder.CreateLoad(f.results[0], ""))
+	default:
+		values := make([]llvm.Value, len(f.results))
+		for i, result := range f.results {
+			values[i] = c.builder.CreateLoad(result, "")
 		}
+		c.builder.CreateAggregateRet(values)
 	}
 }
 
 func (c *compiler) VisitAssignStmt(stmt *ast.AssignStmt) {
-	values := make([]Value, len(stmt.Lhs))
+	switch stmt.Tok {
+	case token.ASSIGN, token.DEFINE:
+	default:
+		// x op= y. The assignment operators are ordered in the same way
+		// as the binary operators, from ADD_ASSIGN to AND_NOT_ASSIGN.
+		op := stmt.Tok - token.ADD_ASSIGN + token.ADD
+		c.assignOp(stmt.Lhs[0], op, stmt.Rhs[0])
+		return
+	}
+
+	var values []Value
 	if len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
-		value := c.VisitExpr(stmt.Rhs[0])
-		ptr := value.LLVMValue()
-		struct_type := value.Type().(*types.Struct)
-		for i := 0; i < len(struct_type.Fields); i++ {
-			t := c.ObjGetType(struct_type.Fields[i])
-			value_ := c.builder.CreateExtractValue(ptr, i, "")
-			values[i] = c.NewLLVMValue(value_, t)
-		}
+		values = c.evalMultiValue(stmt.Rhs[0])
 	} else {
+		values = make([]Value, len(stmt.Lhs))
 		for i, expr := range stmt.Rhs {
 			values[i] = c.VisitExpr(expr)
 		}
 	}
-	for i, expr := range stmt.Lhs {
+	c.assign(stmt.Lhs, values, stmt.Tok == token.DEFINE)
+}
+
+// evalMultiValue evaluates an expression yielding multiple values: a call
+// to a function with multiple results, or the comma-ok form of a channel
+// receive, map index expression or type assertion.
+func (c *compiler) evalMultiValue(expr ast.Expr) []Value {
+	var value Value
+	switch x := unparen(expr).(type) {
+	case *ast.UnaryExpr:
+		if x.Op == token.ARROW {
+			// v, ok := <-ch
+			ch := c.VisitExpr(x.X).(*LLVMValue)
+			value = c.chanRecv(ch, true)
+		}
+	case *ast.IndexExpr:
+		// v, ok := m[k]
+		m := c.VisitExpr(x.X).(*LLVMValue)
+		if _, ismap := types.Underlying(m.Type()).(*types.Map); ismap {
+			value = c.mapLookup(m, c.VisitExpr(x.Index), true)
+		}
+	case *ast.TypeAssertExpr:
+		// v, ok := x.(T)
+		lhs := c.VisitExpr(x.X).(*LLVMValue)
+		typ := c.GetType(x.Type)
+		if _, isiface := types.Underlying(typ).(*types.Interface); isiface {
+			value = lhs.convertI2ICommaOk(typ)
+		} else {
+			value = lhs.convertI2VCommaOk(typ)
+		}
+	}
+	if value == nil {
+		value = c.VisitExpr(expr)
+	}
+
+	struct_value := value.LLVMValue()
+	struct_type := value.Type().(*types.Struct)
+	values := make([]Value, len(struct_type.Fields))
+	for i := range values {
+		t := c.ObjGetType(struct_type.Fields[i])
+		value_ := c.builder.CreateExtractValue(struct_value, i, "")
+		values[i] = c.NewLLVMValue(value_, t)
+	}
+	return values
+}
+
+// commaOkValue creates a {value, ok} pair, as yielded by the comma-ok forms
+// of channel receives, map index expressions and type assertions.
+func (c *compiler) commaOkValue(value Value, ok llvm.Value) *LLVMValue {
+	fields := []*ast.Object{
+		ast.NewObj(ast.Var, "value"), ast.NewObj(ast.Var, "ok")}
+	fields[0].Type = value.Type()
+	fields[1].Type = types.Bool
+	typ := &types.Struct{Fields: fields}
+	result := llvm.ConstNull(c.types.ToLLVM(typ))
+	result = c.builder.CreateInsertValue(result, value.LLVMValue(), 0, "")
+	result = c.builder.CreateInsertValue(result, ok, 1, "")
+	return c.NewLLVMValue(result, typ)
+}
+
+// assign stores each value in the corresponding left-hand side expression.
+// If define is true, then new variables are declared for identifiers.
+func (c *compiler) assign(lhs []ast.Expr, values []Value, define bool) {
+	for i, expr := range lhs {
 		value := values[i]
 		switch x := expr.(type) {
 		case *ast.Ident:
 			if x.Name != "_" {
 				obj := x.Obj
-				if stmt.Tok == token.DEFINE {
+				if define {
 					value_type := value.LLVMValue().Type()
-					ptr := c.builder.CreateAlloca(value_type, x.Name)
+					ptr := c.allocVar(obj, value_type)
 					c.builder.CreateStore(value.LLVMValue(), ptr)
 					llvm_value := c.NewLLVMValue(
 						ptr, &types.Pointer{Base: value.Type()})
@@ -121,13 +201,32 @@ func (c *compiler) VisitAssignStmt(stmt *ast.AssignStmt) {
 				}
 			}
 		default:
-			ptr := c.VisitExpr(expr).(*LLVMValue).pointer
+			ptr := c.lvaluePointer(expr)
 			value = value.Convert(types.Deref(ptr.Type()))
 			c.builder.CreateStore(value.LLVMValue(), ptr.LLVMValue())
 		}
 	}
 }
 
+// assignOp compiles the assignment "x op= y". The address of x is
+// evaluated only once, before y.
+func (c *compiler) assignOp(x ast.Expr, op token.Token, y ast.Expr) {
+	ptr := c.lvaluePointer(x)
+	lhs := ptr.makePointee()
+	result := lhs.BinaryOp(op, c.VisitExpr(y)).Convert(lhs.Type())
+	c.builder.CreateStore(result.LLVMValue(), ptr.LLVMValue())
+}
+
+// lvaluePointer evaluates an expression that is being assigned to, and
+// returns a pointer to its storage. Map index expressions insert the key
+// into the map.
+func (c *compiler) lvaluePointer(expr ast.Expr) *LLVMValue {
+	if x, ok := unparen(expr).(*ast.IndexExpr); ok {
+		return c.visitIndexExpr(x, true).pointer
+	}
+	return c.VisitExpr(expr).(*LLVMValue).pointer
+}
+
 func (c *compiler) VisitIfStmt(stmt *ast.IfStmt) {
 	curr_block := c.builder.GetInsertBlock()
 	resume_block := llvm.AddBasicBlock(curr_block.Parent(), "endif")
@@ -152,38 +251,52 @@ func (c *compiler) VisitIfStmt(stmt *ast.IfStmt) {
 
 	cond_val := c.VisitExpr(stmt.Cond)
 	c.builder.CreateCondBr(cond_val.LLVMValue(), if_block, else_block)
+	// The body may contain nested control statements, so check whether
+	// the block we end up in is terminated, rather than if_block/else_block.
 	c.builder.SetInsertPointAtEnd(if_block)
 	c.VisitBlockStmt(stmt.Body)
-	if in := if_block.LastInstruction(); in.IsNil() || in.IsATerminatorInst().IsNil() {
-		c.builder.CreateBr(resume_block)
-	}
+	c.maybeBranch(resume_block)
 
 	if stmt.Else != nil {
 		c.builder.SetInsertPointAtEnd(else_block)
 		c.VisitStmt(stmt.Else)
-		if in := else_block.LastInstruction(); in.IsNil() || in.IsATerminatorInst().IsNil() {
-			c.builder.CreateBr(resume_block)
-		}
+		c.maybeBranch(resume_block)
 	}
-
-	// If there's a block after the "resume" block (i.e. a nested control
-	// statement), then create a branch to it as the last instruction.
 	c.builder.SetInsertPointAtEnd(resume_block)
-	if last := resume_block.Parent().LastBasicBlock(); last != resume_block {
-		c.builder.CreateBr(last)
-		c.builder.SetInsertPointBefore(resume_block.FirstInstruction())
+}
+
+// maybeBranch creates a branch to the specified block, unless the current
+// block has already been terminated (e.g. by a return statement).
+func (c *compiler) maybeBranch(block llvm.BasicBlock) {
+	in := c.builder.GetInsertBlock().LastInstruction()
+	if in.IsNil() || in.IsATerminatorInst().IsNil() {
+		c.builder.CreateBr(block)
 	}
 }
 
 func (c *compiler) VisitForStmt(stmt *ast.ForStmt) {
 	curr_block := c.builder.GetInsertBlock()
-	var cond_block, loop_block, done_block llvm.BasicBlock
+	var cond_block, loop_block, post_block, done_block llvm.BasicBlock
 	if stmt.Cond != nil {
 		cond_block = llvm.AddBasicBlock(curr_block.Parent(), "cond")
 	}
 	loop_block = llvm.AddBasicBlock(curr_block.Parent(), "loop")
+	if stmt.Post != nil {
+		post_block = llvm.AddBasicBlock(curr_block.Parent(), "post")
+	}
 	done_block = llvm.AddBasicBlock(curr_block.Parent(), "done")
 
+	// Each iteration starts by evaluating the condition, if any. A
+	// "continue" statement branches to the post statement, if any.
+	start_block := loop_block
+	if stmt.Cond != nil {
+		start_block = cond_block
+	}
+	continue_block := start_block
+	if stmt.Post != nil {
+		continue_block = post_block
+	}
+
 	// Is there an initializer? Create a new scope and visit the statement.
 	if stmt.Init != nil {
 		c.PushScope()
@@ -204,14 +317,14 @@ func (c *compiler) VisitForStmt(stmt *ast.ForStmt) {
 
 	// Loop body.
 	c.builder.SetInsertPointAtEnd(loop_block)
+	c.pushBranchTarget(done_block, continue_block)
 	c.VisitBlockStmt(stmt.Body)
+	c.popBranchTarget()
+	c.maybeBranch(continue_block)
 	if stmt.Post != nil {
+		c.builder.SetInsertPointAtEnd(post_block)
 		c.VisitStmt(stmt.Post)
-	}
-	if stmt.Cond != nil {
-		c.builder.CreateBr(cond_block)
-	} else {
-		c.builder.CreateBr(loop_block)
+		c.maybeBranch(start_block)
 	}
 	c.builder.SetInsertPointAtEnd(done_block)
 }
@@ -228,43 +341,31 @@ func (c *compiler) VisitGoStmt(stmt *ast.GoStmt) {
 				"No function found with name '%s'", x.String()))
 		}
 	default:
-		fn = c.VisitExpr(stmt.Call.Fun).(*LLVMValue)
-	}
-
-	// Evaluate arguments, store in a structure on the stack.
-	var args_struct_type llvm.Type
-	var args_mem llvm.Value
-	var args_size llvm.Value
-	if stmt.Call.Args != nil {
-		param_types := make([]llvm.Type, 0)
-		fn_type := types.Deref(fn.Type()).(*types.Func)
-		for _, param := range fn_type.Params {
-			typ := param.Type.(types.Type)
-			param_types = append(param_types, c.types.ToLLVM(typ))
-		}
-		args_struct_type = llvm.StructType(param_types, false)
-		args_mem = c.builder.CreateAlloca(args_struct_type, "")
-		for i, expr := range stmt.Call.Args {
-			value_i := c.VisitExpr(expr)
-			value_i = value_i.Convert(fn_type.Params[i].Type.(types.Type))
-			arg_i := c.builder.CreateGEP(args_mem, []llvm.Value{
-				llvm.ConstInt(llvm.Int32Type(), 0, false),
-				llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}, "")
-			c.builder.CreateStore(value_i.LLVMValue(), arg_i)
-		}
-		args_size = llvm.SizeOf(args_struct_type)
-		args_size = llvm.ConstTrunc(args_size, llvm.Int32Type())
-	} else {
-		args_struct_type = llvm.VoidType()
-		args_mem = llvm.ConstNull(llvm.PointerType(args_struct_type, 0))
-		args_size = llvm.ConstInt(llvm.Int32Type(), 0, false)
+		fn = c.visitCallee(stmt.Call.Fun).(*LLVMValue)
+	}
+
+	// Evaluate the function value and arguments, and store them in a
+	// structure on the stack.
+	fn_value := fn.LLVMValue()
+	args := c.evalCallArgs(fn, stmt.Call.Args)
+	param_types := []llvm.Type{fn_value.Type()}
+	for _, arg := range args {
+		param_types = append(param_types, arg.Type())
 	}
+	args_struct_type := llvm.StructType(param_types, false)
+	args_mem := c.builder.CreateAlloca(args_struct_type, "")
+	c.builder.CreateStore(fn_value, c.builder.CreateStructGEP(args_mem, 0, ""))
+	for i, arg := range args {
+		c.builder.CreateStore(arg, c.builder.CreateStructGEP(args_mem, i+1, ""))
+	}
+	args_size := llvm.SizeOf(args_struct_type)
+	args_size = llvm.ConstTrunc(args_size, llvm.Int32Type())
 
 	// When done, return to where we were.
 	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
 
 	// Create a function that will take a pointer to a structure of the type
-	// defined above, or no parameters if there are none to pass.
+	// defined above.
 	indirect_fn_type := llvm.FunctionType(
 		llvm.VoidType(),
 		[]llvm.Type{llvm.PointerType(args_struct_type, 0)}, false)
@@ -282,19 +383,21 @@ func (c *compiler) VisitGoStmt(stmt *ast.GoStmt) {
 
 	entry := llvm.AddBasicBlock(indirect_fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
-	var args []llvm.Value
-	if stmt.Call.Args != nil {
-		args_mem = indirect_fn.Param(0)
-		args = make([]llvm.Value, len(stmt.Call.Args))
-		for i := range stmt.Call.Args {
-			arg_i := c.builder.CreateGEP(args_mem, []llvm.Value{
-				llvm.ConstInt(llvm.Int32Type(), 0, false),
-				llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}, "")
-			args[i] = c.builder.CreateLoad(arg_i, "")
-		}
+	c.pushFunction(c.NewLLVMValue(indirect_fn, &types.Func{}), nil)
+	args_mem = indirect_fn.Param(0)
+	fn_value = c.builder.CreateLoad(c.builder.CreateStructGEP(args_mem, 0, ""), "")
+	for i := range args {
+		args[i] = c.builder.CreateLoad(c.builder.CreateStructGEP(args_mem, i+1, ""), "")
 	}
-	c.builder.CreateCall(fn.LLVMValue(), args, "")
+	c.callFunc(c.NewLLVMValue(fn_value, fn.Type()), args)
 	c.builder.CreateRetVoid()
+	c.functions = c.functions[0 : len(c.functions)-1]
+}
+
+func (c *compiler) VisitSendStmt(stmt *ast.SendStmt) {
+	ch := c.VisitExpr(stmt.Chan).(*LLVMValue)
+	value := c.VisitExpr(stmt.Value)
+	c.chanSend(ch, value)
 }
 
 func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
@@ -332,6 +435,8 @@ func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
 	}
 
 	c.builder.CreateBr(caseBlocks[0])
+	c.pushBranchTarget(endBlock, llvm.BasicBlock{})
+	defer c.popBranchTarget()
 	for i, stmt := range stmt.Body.List {
 		c.builder.SetInsertPointAtEnd(caseBlocks[i])
 		clause := stmt.(*ast.CaseClause)
@@ -359,14 +464,99 @@ func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
 				c.VisitStmt(stmt)
 			}
 		}
-		if in := stmtBlock.LastInstruction(); in.IsNil() || in.IsATerminatorInst().IsNil() {
-			c.builder.CreateBr(branchBlock)
-		}
+		c.maybeBranch(branchBlock)
 	}
 
 	c.builder.SetInsertPointAtEnd(endBlock)
 }
 
+// pushBranchTarget records the blocks that break and continue statements
+// within the statement being compiled should branch to. If the statement
+// is labeled, then the label is associated with the blocks.
+func (c *compiler) pushBranchTarget(breakBlock, continueBlock llvm.BasicBlock) {
+	f := c.functions[len(c.functions)-1]
+	target := branchTarget{f.label, breakBlock, continueBlock}
+	f.label = nil
+	f.branchTargets = append(f.branchTargets, target)
+}
+
+func (c *compiler) popBranchTarget() {
+	f := c.functions[len(c.functions)-1]
+	f.branchTargets = f.branchTargets[:len(f.branchTargets)-1]
+}
+
+// labelBlock returns the block which begins the statement with the
+// specified label, creating it if necessary (e.g. for a forward goto).
+func (c *compiler) labelBlock(label *ast.Object) llvm.BasicBlock {
+	f := c.functions[len(c.functions)-1]
+	block, ok := f.labels[label]
+	if !ok {
+		if f.labels == nil {
+			f.labels = make(map[*ast.Object]llvm.BasicBlock)
+		}
+		fn := c.builder.GetInsertBlock().Parent()
+		block = llvm.AddBasicBlock(fn, label.Name)
+		f.labels[label] = block
+	}
+	return block
+}
+
+// startUnreachableBlock positions the builder at a new block with no
+// predecessors, for the statements following an unconditional branch.
+func (c *compiler) startUnreachableBlock() {
+	currBlock := c.builder.GetInsertBlock()
+	block := llvm.AddBasicBlock(currBlock.Parent(), "")
+	block.MoveAfter(currBlock)
+	c.builder.SetInsertPointAtEnd(block)
+}
+
+func (c *compiler) VisitLabeledStmt(stmt *ast.LabeledStmt) {
+	block := c.labelBlock(stmt.Label.Obj)
+	c.maybeBranch(block)
+	c.builder.SetInsertPointAtEnd(block)
+
+	f := c.functions[len(c.functions)-1]
+	f.label = stmt.Label.Obj
+	c.VisitStmt(stmt.Stmt)
+	f.label = nil
+}
+
+func (c *compiler) VisitBranchStmt(stmt *ast.BranchStmt) {
+	f := c.functions[len(c.functions)-1]
+	switch stmt.Tok {
+	case token.BREAK, token.CONTINUE:
+		// Find the innermost enclosing statement with the specified
+		// label, or the innermost (loop, if continuing) statement.
+		var target *branchTarget
+		for i := len(f.branchTargets) - 1; i >= 0; i-- {
+			t := &f.branchTargets[i]
+			if stmt.Label != nil {
+				if t.label == stmt.Label.Obj {
+					target = t
+					break
+				}
+			} else if stmt.Tok == token.BREAK || !t.continueBlock.IsNil() {
+				target = t
+				break
+			}
+		}
+		if target == nil {
+			panic(fmt.Sprintf("invalid %s statement", stmt.Tok))
+		}
+		if stmt.Tok == token.BREAK {
+			c.builder.CreateBr(target.breakBlock)
+		} else {
+			c.builder.CreateBr(target.continueBlock)
+		}
+	case token.GOTO:
+		c.builder.CreateBr(c.labelBlock(stmt.Label.Obj))
+	default:
+		// fallthrough is handled by VisitSwitchStmt.
+		panic(fmt.Sprintf("%s statement out of place", stmt.Tok))
+	}
+	c.startUnreachableBlock()
+}
+
 func (c *compiler) VisitStmt(stmt ast.Stmt) {
 	if c.logger != nil {
 		c.logger.Println("Compile statement:", reflect.TypeOf(stmt),
@@ -393,6 +583,22 @@ func (c *compiler) VisitStmt(stmt ast.Stmt) {
 		c.VisitGoStmt(x)
 	case *ast.SwitchStmt:
 		c.VisitSwitchStmt(x)
+	case *ast.TypeSwitchStmt:
+		c.VisitTypeSwitchStmt(x)
+	case *ast.SendStmt:
+		c.VisitSendStmt(x)
+	case *ast.SelectStmt:
+		c.VisitSelectStmt(x)
+	case *ast.DeferStmt:
+		c.VisitDeferStmt(x)
+	case *ast.RangeStmt:
+		c.VisitRangeStmt(x)
+	case *ast.BranchStmt:
+		c.VisitBranchStmt(x)
+	case *ast.LabeledStmt:
+		c.VisitLabeledStmt(x)
+	case *ast.EmptyStmt:
+		// no-op
 	default:
 		panic(fmt.Sprintf("Unhandled Stmt node: %s", reflect.TypeOf(stmt)))
 	}
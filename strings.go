@@ -73,4 +73,70 @@ func (c *compiler) compareStrings(lhs, rhs *LLVMValue, op token.Token) *LLVMValu
 	return c.NewLLVMValue(result, types.Bool)
 }
 
+// stringIndex returns the byte at the specified index of a string. The
+// result is not addressable, as strings are immutable.
+func (c *compiler) stringIndex(s, index Value) *LLVMValue {
+	ptrType := c.target.IntPtrType()
+	stringType := c.types.ToLLVM(types.String)
+	paramTypes := []llvm.Type{stringType, ptrType}
+	funcType := llvm.FunctionType(llvm.Int8Type(), paramTypes, false)
+	strindex := c.namedFunction("runtime.strindex", funcType)
+	args := []llvm.Value{
+		s.LLVMValue(),
+		index.Convert(types.Int).LLVMValue()}
+	result := c.createCall(strindex, args)
+	return c.NewLLVMValue(result, types.Byte)
+}
+
+// convertToString converts a byte slice, rune slice, integer or string to
+// the string type typ.
+func (c *compiler) convertToString(v *LLVMValue, typ types.Type) *LLVMValue {
+	stringType := c.types.ToLLVM(types.String)
+	var fn, arg llvm.Value
+	if slicetyp, isslice := types.Underlying(v.Type()).(*types.Slice); isslice {
+		name := "runtime.strfrombytes"
+		if isRuneType(slicetyp.Elt) {
+			name = "runtime.strfromrunes"
+		}
+		paramTypes := []llvm.Type{c.runtimeSliceType()}
+		fn = c.namedFunction(name, llvm.FunctionType(stringType, paramTypes, false))
+		arg = c.toRuntimeSlice(v)
+	} else if isStringType(v.Type()) {
+		return c.NewLLVMValue(v.LLVMValue(), typ)
+	} else {
+		paramTypes := []llvm.Type{llvm.Int64Type()}
+		fn = c.namedFunction("runtime.strfromrune", llvm.FunctionType(stringType, paramTypes, false))
+		arg = v.Convert(types.Int64).LLVMValue()
+	}
+	result := c.createCall(fn, []llvm.Value{arg})
+	return c.NewLLVMValue(result, typ)
+}
+
+// convertFromString converts a string to the byte or rune slice type typ.
+func (c *compiler) convertFromString(v *LLVMValue, typ types.Type) *LLVMValue {
+	name := "runtime.strtobytes"
+	if isRuneType(types.Underlying(typ).(*types.Slice).Elt) {
+		name = "runtime.strtorunes"
+	}
+	runtimeSliceType := c.runtimeSliceType()
+	paramTypes := []llvm.Type{c.types.ToLLVM(types.String)}
+	fn := c.namedFunction(name, llvm.FunctionType(runtimeSliceType, paramTypes, false))
+	result := c.createCall(fn, []llvm.Value{v.LLVMValue()})
+	return c.fromRuntimeSlice(result, typ)
+}
+
+func isRuneType(t types.Type) bool {
+	for {
+		switch x := t.(type) {
+		case *types.Name:
+			t = x.Underlying
+		case *types.Basic:
+			return x.Kind == types.Int32Kind
+		default:
+			return false
+		}
+	}
+	panic("unreachable")
+}
+
 // vim: set ft=go:
@@ -83,6 +83,8 @@ func (c *compiler) GetType(expr ast.Expr) types.Type {
 		return c.VisitInterfaceType(x)
 	case *ast.StarExpr:
 		return &types.Pointer{Base: c.GetType(x.X)}
+	case *ast.ChanType:
+		return &types.Chan{Dir: x.Dir, Elt: c.GetType(x.Value)}
 	case *ast.Ellipsis:
 		return c.GetType(x.Elt)
 	default:
@@ -236,6 +236,9 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 			// TODO check if rhs is non-const.
 			//if xUntyped && !isConst(x.Y) {
 			//}
+			if !xUntyped {
+				return xType
+			}
 			return Int
 		default:
 			if xUntyped && yUntyped {
@@ -312,6 +315,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 		}
 
 		args := x.Args
+		hasEllipsis := x.Ellipsis.IsValid()
 		switch x := x.Fun.(type) {
 		case *ast.SelectorExpr:
 			// check for unsafe functions.
@@ -345,10 +349,68 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 			if x.Obj.Kind == ast.Fun && x.Obj.Decl == nil {
 				// TODO check args
 				switch x.Name {
-				//case "append":
-				//case "cap":
-				//case "close":
-				//case "complex":
+				case "append":
+					s := c.checkExpr(args[0], nil)
+					slice, ok := Underlying(s).(*Slice)
+					if !ok {
+						msg := c.errorf(x.Pos(), "first argument to append must be a slice")
+						return &Bad{Msg: msg}
+					}
+					if hasEllipsis {
+						// The final argument must be assignable to []T,
+						// or be a string if T is byte.
+						if len(args) != 2 {
+							msg := c.errorf(x.Pos(), "can only use ... with final argument to append")
+							return &Bad{Msg: msg}
+						}
+						src := c.checkExpr(args[1], nil)
+						if b, ok := basicType(src); ok && b.Kind == StringKind {
+							if b, ok := basicType(slice.Elt); ok && b.Kind == Uint8Kind {
+								return s
+							}
+						}
+						if !assignable(src, &Slice{Elt: slice.Elt}) {
+							msg := c.errorf(args[1].Pos(), "cannot append elements of the wrong type")
+							return &Bad{Msg: msg}
+						}
+						return s
+					}
+					for _, arg := range args[1:] {
+						if !assignable(c.checkExpr(arg, nil), slice.Elt) {
+							msg := c.errorf(arg.Pos(), "cannot append a value of the wrong type")
+							return &Bad{Msg: msg}
+						}
+					}
+					return s
+				case "cap":
+					// TODO check argument type.
+					c.checkExpr(args[0], nil)
+					return Int
+				case "close":
+					ch := c.checkExpr(args[0], nil)
+					if t, ok := Underlying(ch).(*Chan); !ok || t.Dir == ast.RECV {
+						msg := c.errorf(x.Pos(), "close must be called with a sendable channel")
+						return &Bad{Msg: msg}
+					}
+					return nil
+				case "complex":
+					re := c.checkExpr(args[0], nil)
+					im := c.checkExpr(args[1], nil)
+					t := re
+					if _, untyped := re.(*Basic); untyped {
+						t = im
+					}
+					switch t {
+					case Float32:
+						return Complex64
+					case Float64:
+						return Complex128
+					}
+					if _, untyped := t.(*Basic); untyped {
+						return Complex128.Underlying
+					}
+					msg := c.errorf(x.Pos(), "complex must be called with floating point arguments")
+					return &Bad{Msg: msg}
 				case "copy":
 					/*dst := */ c.checkExpr(args[0], nil)
 					/*src := */ c.checkExpr(args[1], nil)
@@ -394,14 +456,21 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 						return Float32
 					case Complex128:
 						return Float64
+					case Complex128.Underlying:
+						return Float64.Underlying
 					default:
 						msg := c.errorf(x.Pos(), "%s must be called with a complex type", x.Name)
 						return &Bad{Msg: msg}
 					}
 				case "panic":
-					// TODO check arg is an expression.
+					if len(args) != 1 {
+						msg := c.errorf(x.Pos(), "panic must be called with exactly one argument")
+						return &Bad{Msg: msg}
+					}
+					c.checkExpr(args[0], nil)
 					return nil
-				//case "recover":
+				case "recover":
+					return &Interface{}
 				default:
 					panic(fmt.Sprintf("unhandled builtin function: %s", x.Name))
 				}
@@ -457,7 +526,13 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 		}
 
 		name := x.Sel.Name
-		t := c.checkExpr(x.X, nil)
+		var t Type
+		isMethodExpr := isType(x.X)
+		if isMethodExpr {
+			t = c.makeType(x.X, true)
+		} else {
+			t = c.checkExpr(x.X, nil)
+		}
 		if iface, ok := Underlying(t).(*Interface); ok {
 			i := sort.Search(len(iface.Methods), func(i int) bool {
 				return iface.Methods[i].Name >= name
@@ -526,6 +601,16 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 			return &Bad{Msg: msg}
 		} else {
 			c.checkObj(x.Sel.Obj, false)
+			if fn, ok := x.Sel.Obj.Type.(*Func); ok && x.Sel.Obj.Kind == ast.Fun {
+				if isMethodExpr {
+					return MethodFuncType(fn, t)
+				}
+				return MethodFuncType(fn, nil)
+			}
+			if isMethodExpr {
+				msg := c.errorf(x.Pos(), "%s.%s is not a method", x.X, x.Sel)
+				return &Bad{Msg: msg}
+			}
 			return x.Sel.Obj.Type.(Type)
 		}
 
@@ -559,7 +644,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 					assignees[0].Obj.Type = t.Elt
 				}
 				if assignees[1] != nil && assignees[1].Obj.Type == nil {
-					assignees[0].Obj.Type = Bool
+					assignees[1].Obj.Type = Bool
 				}
 			}
 			return t.Elt
@@ -614,24 +699,30 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 		if x.High != nil {
 			c.checkExpr(x.High, nil)
 		}
+		if x.Max != nil {
+			c.checkExpr(x.Max, nil)
+		}
 
 		lhs := c.checkExpr(x.X, nil)
 		switch t := Underlying(lhs).(type) {
 		case *Pointer:
-			if t, ok := t.Base.(*Array); ok {
+			if t, ok := Underlying(t.Base).(*Array); ok {
 				return &Slice{Elt: t.Elt}
 			}
 		case *Array:
-			// TODO check array is addressable.
+			if !c.isAddressable(x.X) {
+				msg := c.errorf(x.Pos(), "cannot slice unaddressable array")
+				return &Bad{Msg: msg}
+			}
 			return &Slice{Elt: t.Elt}
 		case *Slice:
 			return lhs
 		case *Name:
-			if Underlying(t) == Underlying(String) {
+			if Underlying(t) == Underlying(String) && !x.Slice3 {
 				return lhs
 			}
 		case *Basic:
-			if t == String.Underlying {
+			if t == String.Underlying && !x.Slice3 {
 				return lhs
 			}
 		}
@@ -640,7 +731,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 
 	case *ast.FuncLit:
 		t := c.makeType(x.Type, false)
-		c.checkStmt(x.Body)
+		c.checkFuncBody(x.Body)
 		return t
 	}
 
@@ -785,6 +876,111 @@ func isType(x ast.Expr) bool {
 	return false
 }
 
+// isAddressable checks if an expression is addressable: a variable, pointer
+// indirection or slice indexing operation, or a field selector or array
+// indexing operation of an addressable operand.
+func (c *checker) isAddressable(x ast.Expr) bool {
+	switch t := x.(type) {
+	case *ast.Ident:
+		return t.Obj != nil && t.Obj.Kind == ast.Var
+	case *ast.ParenExpr:
+		return c.isAddressable(t.X)
+	case *ast.StarExpr:
+		return true
+	case *ast.IndexExpr:
+		switch Underlying(c.checkExpr(t.X, nil)).(type) {
+		case *Slice, *Pointer:
+			return true
+		case *Array:
+			return c.isAddressable(t.X)
+		}
+	case *ast.SelectorExpr:
+		// qualified identifier
+		if ident, ok := t.X.(*ast.Ident); ok {
+			if obj := ident.Obj; obj != nil && obj.Kind == ast.Pkg {
+				pkgscope := obj.Data.(*ast.Scope)
+				obj := pkgscope.Lookup(t.Sel.Name)
+				return obj != nil && obj.Kind == ast.Var
+			}
+		}
+		if _, ok := Underlying(c.checkExpr(t.X, nil)).(*Pointer); ok {
+			return true
+		}
+		return c.isAddressable(t.X)
+	}
+	return false
+}
+
+// assignable reports whether a value of type x is assignable to a variable
+// of type t. A nil x is the type of the predeclared identifier nil. Whether
+// x implements an interface type t, and whether an untyped constant is
+// representable by t, are not checked.
+func assignable(x, t Type) bool {
+	if x == nil {
+		switch Underlying(t).(type) {
+		case *Pointer, *Func, *Slice, *Map, *Chan, *Interface:
+			return true
+		}
+		return false
+	}
+	if _, bad := t.(*Bad); bad || Identical(x, t) {
+		return true
+	}
+	if _, ok := Underlying(t).(*Interface); ok {
+		return true
+	}
+
+	xb, xbasic := basicType(x)
+	tb, tbasic := basicType(t)
+	if xbasic || tbasic {
+		if !xbasic || !tbasic {
+			return false
+		}
+		if _, untyped := x.(*Basic); untyped {
+			switch xb.Kind {
+			case BoolKind, StringKind:
+				return tb.Kind == xb.Kind
+			}
+			return tb.Kind >= IntKind && tb.Kind <= Complex128Kind
+		}
+		// byte and rune are aliases for uint8 and int32.
+		return xb.Kind == tb.Kind && isUniverseType(x) && isUniverseType(t)
+	}
+
+	// Values may be assigned between types with identical underlying
+	// types, if at least one of them is unnamed; and a bidirectional
+	// channel may be assigned to an unnamed directional channel type.
+	_, xnamed := x.(*Name)
+	_, tnamed := t.(*Name)
+	if xnamed && tnamed {
+		return false
+	}
+	if Identical(Underlying(x), Underlying(t)) {
+		return true
+	}
+	if xc, ok := Underlying(x).(*Chan); ok && xc.Dir == ast.SEND|ast.RECV {
+		if tc, ok := Underlying(t).(*Chan); ok {
+			return Identical(xc.Elt, tc.Elt)
+		}
+	}
+	return false
+}
+
+// basicType returns the basic type underlying t, if any.
+func basicType(t Type) (*Basic, bool) {
+	if n, ok := t.(*Name); ok {
+		return basicType(n.Underlying)
+	}
+	b, ok := t.(*Basic)
+	return b, ok
+}
+
+// isUniverseType reports whether t is one of the predeclared types.
+func isUniverseType(t Type) bool {
+	n, ok := t.(*Name)
+	return ok && n.Obj != nil && Universe.Lookup(n.Obj.Name) == n.Obj
+}
+
 // checkStmt type checks a statement.
 func (c *checker) checkStmt(s ast.Stmt) {
 	switch s := s.(type) {
@@ -834,7 +1030,10 @@ func (c *checker) checkStmt(s ast.Stmt) {
 				c.checkObj(name.Obj, true)
 			}
 		}
-	//case *ast.DeferStmt:
+
+	case *ast.DeferStmt:
+		c.checkExpr(s.Call, nil)
+
 	//case *ast.EmptyStmt:
 
 	case *ast.ForStmt:
@@ -849,7 +1048,9 @@ func (c *checker) checkStmt(s ast.Stmt) {
 		c.checkStmt(s.Body)
 
 	//case *ast.IncDecStmt:
-	//case *ast.GoStmt:
+
+	case *ast.GoStmt:
+		c.checkExpr(s.Call, nil)
 
 	case *ast.IfStmt:
 		if s.Init != nil {
@@ -899,13 +1100,17 @@ func (c *checker) checkStmt(s ast.Stmt) {
 
 		// TODO check key, value are addressable and assignable from range
 		// values.
-		if ident, ok := s.Key.(*ast.Ident); ok && ident.Obj.Type == nil {
+		if ident, ok := s.Key.(*ast.Ident); ok && ident.Name == "_" {
+			// blank identifier
+		} else if ok && ident.Obj.Type == nil {
 			ident.Obj.Type = k
 		} else {
 			c.checkExpr(s.Key, nil)
 		}
 		if s.Value != nil {
-			if ident, ok := s.Value.(*ast.Ident); ok && ident.Obj.Type == nil {
+			if ident, ok := s.Value.(*ast.Ident); ok && ident.Name == "_" {
+				// blank identifier
+			} else if ok && ident.Obj.Type == nil {
 				ident.Obj.Type = v
 			} else {
 				c.checkExpr(s.Value, nil)
@@ -921,8 +1126,31 @@ func (c *checker) checkStmt(s ast.Stmt) {
 			c.checkExpr(e, nil)
 		}
 
-	//case *ast.SelectStmt:
-	//case *ast.SendStmt:
+	case *ast.SelectStmt:
+		for _, s_ := range s.Body.List {
+			cc := s_.(*ast.CommClause)
+			if cc.Comm != nil {
+				if !isCommStmt(cc.Comm) {
+					c.errorf(cc.Comm.Pos(),
+						"select case must be receive, send or assign recv")
+				} else {
+					c.checkStmt(cc.Comm)
+				}
+			}
+			for _, s := range cc.Body {
+				c.checkStmt(s)
+			}
+		}
+
+	case *ast.SendStmt:
+		// TODO check value is assignable to the channel's element type.
+		ch := c.checkExpr(s.Chan, nil)
+		c.checkExpr(s.Value, nil)
+		if t, ok := Underlying(ch).(*Chan); !ok {
+			c.errorf(s.Pos(), "cannot send to non-channel")
+		} else if t.Dir == ast.RECV {
+			c.errorf(s.Pos(), "cannot send to receive-only channel")
+		}
 
 	case *ast.SwitchStmt:
 		if s.Init != nil {
@@ -944,13 +1172,255 @@ func (c *checker) checkStmt(s ast.Stmt) {
 			}
 		}
 
-	//case *ast.TypeSwitchStmt:
+	case *ast.TypeSwitchStmt:
+		if s.Init != nil {
+			c.checkStmt(s.Init)
+		}
+
+		// The switch guard is either "x.(type)" or "v := x.(type)".
+		var lhs *ast.Ident
+		var rhs ast.Expr
+		switch a := s.Assign.(type) {
+		case *ast.ExprStmt:
+			rhs = a.X
+		case *ast.AssignStmt:
+			if len(a.Lhs) == 1 && len(a.Rhs) == 1 && a.Tok == token.DEFINE {
+				lhs, _ = a.Lhs[0].(*ast.Ident)
+				rhs = a.Rhs[0]
+			}
+		}
+		for paren, ok := rhs.(*ast.ParenExpr); ok; paren, ok = rhs.(*ast.ParenExpr) {
+			rhs = paren.X
+		}
+		x, ok := rhs.(*ast.TypeAssertExpr)
+		if !ok || x.Type != nil {
+			c.errorf(s.Assign.Pos(), "invalid type switch guard")
+			return
+		}
+		xtyp := c.checkExpr(x.X, nil)
+		if _, ok := Underlying(xtyp).(*Interface); !ok {
+			c.errorf(x.X.Pos(), "cannot type switch on non-interface value")
+			return
+		}
+
+		for _, s_ := range s.Body.List {
+			cc := s_.(*ast.CaseClause)
+			var typ Type
+			for _, e := range cc.List {
+				// TODO check for impossible cases, where the type does
+				// not implement the switch guard's interface type.
+				if ident, ok := e.(*ast.Ident); ok && ident.Obj == Nil {
+					typ = nil
+				} else {
+					typ = c.makeType(e, true)
+				}
+			}
+
+			// In clauses listing exactly one type, the variable has that
+			// type; otherwise it has the type of the guard's expression.
+			if lhs != nil && lhs.Obj != nil {
+				if len(cc.List) != 1 || typ == nil {
+					typ = xtyp
+				}
+				lhs.Obj.Type = typ
+			}
+			for _, s := range cc.Body {
+				c.checkStmt(s)
+			}
+		}
+
 
 	default:
 		panic(fmt.Sprintf("unimplemented %T", s))
 	}
 }
 
+// isCommStmt reports whether s is a valid communication statement for a
+// select case: a send, a receive, or an assignment of a receive.
+func isCommStmt(s ast.Stmt) bool {
+	var x ast.Expr
+	switch s := s.(type) {
+	case *ast.SendStmt:
+		return true
+	case *ast.ExprStmt:
+		x = s.X
+	case *ast.AssignStmt:
+		if len(s.Lhs) > 2 || len(s.Rhs) != 1 {
+			return false
+		}
+		x = s.Rhs[0]
+	}
+	for {
+		if paren, ok := x.(*ast.ParenExpr); ok {
+			x = paren.X
+		} else {
+			break
+		}
+	}
+	recv, ok := x.(*ast.UnaryExpr)
+	return ok && recv.Op == token.ARROW
+}
+
+// checkFuncBody type checks a function body, and checks the use of labels
+// within it.
+func (c *checker) checkFuncBody(body *ast.BlockStmt) {
+	c.checkStmt(body)
+	c.checkLabels(body)
+}
+
+// labelBlock is a block of statements, used for checking branches.
+type labelBlock struct {
+	parent *labelBlock
+	index  int // index of the enclosing statement in parent.stmts
+	stmts  []ast.Stmt
+}
+
+// labelPos records the position of a labeled statement or goto statement:
+// the block it is in, and its index in that block's statements.
+type labelPos struct {
+	block *labelBlock
+	index int
+}
+
+// labelChecker checks the labels and branch statements of a function body.
+type labelChecker struct {
+	*checker
+	labels  map[*ast.Object]labelPos
+	gotos   map[*ast.BranchStmt]labelPos
+	used    map[*ast.Object]bool
+	targets []*ast.LabeledStmt // enclosing labeled statements
+}
+
+// checkLabels checks that every label is used, that break and continue
+// statements refer to the label of an enclosing statement, and that goto
+// statements do not jump into a block or over a variable declaration.
+func (c *checker) checkLabels(body *ast.BlockStmt) {
+	lc := &labelChecker{
+		checker: c,
+		labels:  make(map[*ast.Object]labelPos),
+		gotos:   make(map[*ast.BranchStmt]labelPos),
+		used:    make(map[*ast.Object]bool),
+	}
+	lc.walkBlock(&labelBlock{stmts: body.List}, nil, 0)
+
+	for s, gotoPos := range lc.gotos {
+		labelPos, ok := lc.labels[s.Label.Obj]
+		if !ok {
+			continue // undefined labels are reported by the parser
+		}
+
+		// The label's block must be the goto's block, or enclose it.
+		b, i := gotoPos.block, gotoPos.index
+		for b != nil && b != labelPos.block {
+			b, i = b.parent, b.index
+		}
+		if b == nil {
+			c.errorf(s.Pos(), "goto %s jumps into block", s.Label.Name)
+			continue
+		}
+
+		// Check for variable declarations between a goto and a label
+		// following it.
+		for j := i + 1; j < labelPos.index; j++ {
+			if isVarDecl(b.stmts[j]) {
+				c.errorf(s.Pos(), "goto %s jumps over variable declaration at %s",
+					s.Label.Name, c.fset.Position(b.stmts[j].Pos()))
+				break
+			}
+		}
+	}
+
+	for obj, pos := range lc.labels {
+		if !lc.used[obj] {
+			s := pos.block.stmts[pos.index]
+			c.errorf(s.Pos(), "label %s defined and not used", obj.Name)
+		}
+	}
+}
+
+// isVarDecl reports whether s declares variables.
+func isVarDecl(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.DeclStmt:
+		return s.Decl.(*ast.GenDecl).Tok == token.VAR
+	case *ast.AssignStmt:
+		return s.Tok == token.DEFINE
+	}
+	return false
+}
+
+func (lc *labelChecker) walkBlock(b *labelBlock, parent *labelBlock, index int) {
+	b.parent, b.index = parent, index
+	for i, s := range b.stmts {
+		lc.walkStmt(s, b, i)
+	}
+}
+
+func (lc *labelChecker) walkStmt(s ast.Stmt, b *labelBlock, i int) {
+	switch s := s.(type) {
+	case *ast.LabeledStmt:
+		if s.Label.Obj != nil {
+			lc.labels[s.Label.Obj] = labelPos{b, i}
+		}
+		lc.targets = append(lc.targets, s)
+		lc.walkStmt(s.Stmt, b, i)
+		lc.targets = lc.targets[:len(lc.targets)-1]
+
+	case *ast.BranchStmt:
+		if s.Label == nil || s.Label.Obj == nil {
+			return
+		}
+		lc.used[s.Label.Obj] = true
+		switch s.Tok {
+		case token.GOTO:
+			lc.gotos[s] = labelPos{b, i}
+		case token.BREAK, token.CONTINUE:
+			// The label must be that of an enclosing "for", "switch" or
+			// "select" statement ("for" only for continue).
+			var target ast.Stmt
+			for _, t := range lc.targets {
+				if t.Label.Obj == s.Label.Obj {
+					target = t.Stmt
+				}
+			}
+			valid := false
+			switch target.(type) {
+			case *ast.ForStmt, *ast.RangeStmt:
+				valid = true
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				valid = s.Tok == token.BREAK
+			}
+			if !valid {
+				lc.errorf(s.Pos(), "invalid %s label %s", s.Tok, s.Label.Name)
+			}
+		}
+
+	case *ast.BlockStmt:
+		lc.walkBlock(&labelBlock{stmts: s.List}, b, i)
+	case *ast.IfStmt:
+		lc.walkBlock(&labelBlock{stmts: s.Body.List}, b, i)
+		if s.Else != nil {
+			lc.walkBlock(&labelBlock{stmts: []ast.Stmt{s.Else}}, b, i)
+		}
+	case *ast.ForStmt:
+		lc.walkBlock(&labelBlock{stmts: s.Body.List}, b, i)
+	case *ast.RangeStmt:
+		lc.walkBlock(&labelBlock{stmts: s.Body.List}, b, i)
+	case *ast.SwitchStmt:
+		for _, cc := range s.Body.List {
+			lc.walkBlock(&labelBlock{stmts: cc.(*ast.CaseClause).Body}, b, i)
+		}
+	case *ast.TypeSwitchStmt:
+		for _, cc := range s.Body.List {
+			lc.walkBlock(&labelBlock{stmts: cc.(*ast.CaseClause).Body}, b, i)
+		}
+	case *ast.SelectStmt:
+		for _, cc := range s.Body.List {
+			lc.walkBlock(&labelBlock{stmts: cc.(*ast.CommClause).Body}, b, i)
+		}
+	}
+}
+
 // checkObj type checks an object.
 func (c *checker) checkObj(obj *ast.Object, ref bool) {
 	if obj.Type != nil {
@@ -1040,7 +1510,7 @@ func (c *checker) checkObj(obj *ast.Object, ref bool) {
 			// Only check body of non-method functions. We check method
 			// bodies later, to avoid references to incomplete types.
 			if fndecl.Body != nil {
-				c.checkStmt(fndecl.Body)
+				c.checkFuncBody(fndecl.Body)
 			}
 		}
 
@@ -1072,7 +1542,7 @@ func Check(fset *token.FileSet, pkg *ast.Package) (types map[ast.Expr]Type, err
 	for _, methods := range c.methods {
 		for _, m := range methods {
 			if f := m.Decl.(*ast.FuncDecl); f.Body != nil {
-				c.checkStmt(f.Body)
+				c.checkFuncBody(f.Body)
 			}
 		}
 	}
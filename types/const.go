@@ -109,7 +109,7 @@ func (x *Const) Match(y *Const) (u, v *Const) {
 		case *big.Rat:
 			u, v = x, y
 		case cmplx:
-			u, v = &Const{cmplx{a, big.NewRat(0, 0)}}, y
+			u, v = &Const{cmplx{a, big.NewRat(0, 1)}}, y
 		}
 	case cmplx:
 		switch y.Val.(type) {
@@ -128,6 +128,20 @@ func (x *Const) Match(y *Const) (u, v *Const) {
 	return
 }
 
+// Complex returns the real and imaginary parts of a numeric constant. The
+// imaginary part of an integer or floating point constant is zero.
+func (x *Const) Complex() (re, im *big.Rat) {
+	switch x := x.Val.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(x), big.NewRat(0, 1)
+	case *big.Rat:
+		return x, big.NewRat(0, 1)
+	case cmplx:
+		return x.re, x.im
+	}
+	panic("unreachable")
+}
+
 // Convert attempts to convert the constant x to a given type.
 // If the attempt is successful, the result is the new constant;
 // otherwise the result is invalid.
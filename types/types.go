@@ -209,6 +209,22 @@ func Underlying(typ Type) Type {
 	return typ
 }
 
+// MethodFuncType returns the type of a method with type fn as a function
+// type. If recv is non-nil, then the function takes a receiver of type recv
+// as its first parameter, as for a method expression; otherwise it has the
+// method's signature without the receiver, as for a method value.
+func MethodFuncType(fn *Func, recv Type) *Func {
+	f := &Func{Results: fn.Results, IsVariadic: fn.IsVariadic}
+	if recv != nil {
+		obj := ast.NewObj(ast.Var, "")
+		obj.Type = recv
+		f.Params = append(ObjList{obj}, fn.Params...)
+	} else {
+		f.Params = fn.Params
+	}
+	return f
+}
+
 // An ObjList represents an ordered (in some fashion) list of objects.
 type ObjList []*ast.Object
 
@@ -257,8 +273,7 @@ func Identical(x, y Type) bool {
 
 	case *Basic:
 		if y, ok := y.(*Basic); ok {
-			panic("unimplemented")
-			_ = y
+			return x.Kind == y.Kind
 		}
 
 	case *Array:
@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// VisitTypeSwitchStmt compiles a type switch statement. Each case clause
+// compares the dynamic type of the interface value with the clause's types
+// in turn; interface types are matched by looking up their methods in the
+// dynamic type's method table. If none of the clauses match, the default
+// clause (if any) is chosen, regardless of its position.
+func (c *compiler) VisitTypeSwitchStmt(stmt *ast.TypeSwitchStmt) {
+	if stmt.Init != nil {
+		c.PushScope()
+		defer c.PopScope()
+		c.VisitStmt(stmt.Init)
+	}
+
+	// The switch guard is either "x.(type)" or "v := x.(type)".
+	var assignIdent *ast.Ident
+	var typeAssertExpr *ast.TypeAssertExpr
+	switch assign := stmt.Assign.(type) {
+	case *ast.ExprStmt:
+		typeAssertExpr = unparen(assign.X).(*ast.TypeAssertExpr)
+	case *ast.AssignStmt:
+		assignIdent = assign.Lhs[0].(*ast.Ident)
+		typeAssertExpr = unparen(assign.Rhs[0]).(*ast.TypeAssertExpr)
+	}
+	value := c.VisitExpr(typeAssertExpr.X).(*LLVMValue)
+	if len(stmt.Body.List) == 0 {
+		return
+	}
+
+	// Separate the default clause from the others.
+	var defaultClause *ast.CaseClause
+	clauses := make([]*ast.CaseClause, 0, len(stmt.Body.List))
+	for _, stmt := range stmt.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		if clause.List == nil {
+			defaultClause = clause
+		} else {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	startBlock := c.builder.GetInsertBlock()
+	endBlock := llvm.AddBasicBlock(startBlock.Parent(), "end")
+	endBlock.MoveAfter(startBlock)
+	defaultBlock := endBlock
+	if defaultClause != nil {
+		defaultBlock = llvm.InsertBasicBlock(endBlock, "")
+	}
+
+	c.pushBranchTarget(endBlock, llvm.BasicBlock{})
+	defer c.popBranchTarget()
+	for _, clause := range clauses {
+		// Test each of the clause's types, branching to the body on the
+		// first match. If none match, we continue with the next clause.
+		bodyBlock := llvm.InsertBasicBlock(defaultBlock, "")
+		var ifaceValue Value
+		for _, expr := range clause.List {
+			nextBlock := llvm.InsertBasicBlock(bodyBlock, "")
+			var match llvm.Value
+			if c.isNilIdent(expr) {
+				typ := value.interfaceType()
+				match = c.builder.CreateICmp(
+					llvm.IntEQ, typ, llvm.ConstNull(typ.Type()), "")
+			} else {
+				typ := c.GetType(expr)
+				if iface, ok := types.Underlying(typ).(*types.Interface); ok {
+					match, ifaceValue = value.convertI2IDynamic(iface)
+				} else {
+					match = value.interfaceTypeEquals(typ)
+				}
+			}
+			c.builder.CreateCondBr(match, bodyBlock, nextBlock)
+			c.builder.SetInsertPointAtEnd(nextBlock)
+		}
+		noMatchBlock := c.builder.GetInsertBlock()
+
+		// In clauses listing exactly one type, the variable declared in
+		// the switch guard has that type; otherwise it has the type of
+		// the switch guard's expression.
+		c.builder.SetInsertPointAtEnd(bodyBlock)
+		if assignIdent != nil {
+			var bound Value = value
+			if len(clause.List) == 1 && !c.isNilIdent(clause.List[0]) {
+				if ifaceValue != nil {
+					bound = ifaceValue
+				} else {
+					bound = value.loadI2V(c.GetType(clause.List[0]))
+				}
+			}
+			c.bindTypeSwitchVar(assignIdent.Obj, bound)
+		}
+		for _, stmt := range clause.Body {
+			c.VisitStmt(stmt)
+		}
+		c.maybeBranch(endBlock)
+		c.builder.SetInsertPointAtEnd(noMatchBlock)
+	}
+	c.builder.CreateBr(defaultBlock)
+
+	if defaultClause != nil {
+		c.builder.SetInsertPointAtEnd(defaultBlock)
+		if assignIdent != nil {
+			c.bindTypeSwitchVar(assignIdent.Obj, value)
+		}
+		for _, stmt := range defaultClause.Body {
+			c.VisitStmt(stmt)
+		}
+		c.maybeBranch(endBlock)
+	}
+	c.builder.SetInsertPointAtEnd(endBlock)
+}
+
+// bindTypeSwitchVar binds the variable declared in a type switch guard to
+// a new variable holding the specified value, for the clause being
+// compiled.
+func (c *compiler) bindTypeSwitchVar(obj *ast.Object, value Value) {
+	typ := value.Type()
+	stackvalue := c.allocVar(obj, c.types.ToLLVM(typ))
+	c.builder.CreateStore(value.LLVMValue(), stackvalue)
+	ptrvalue := c.NewLLVMValue(stackvalue, &types.Pointer{Base: typ})
+	obj.Data = ptrvalue.makePointee()
+	obj.Type = typ
+}
+
+// isNilIdent reports whether x is the predeclared identifier "nil".
+func (c *compiler) isNilIdent(x ast.Expr) bool {
+	ident, ok := unparen(x).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	if ident.Obj == nil {
+		ident.Obj = c.LookupObj(ident.Name)
+	}
+	return ident.Obj == types.Nil
+}
+
+// vim: set ft=go :
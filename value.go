@@ -29,6 +29,7 @@ import (
 	"go/token"
 	"math"
 	"math/big"
+	"unicode"
 )
 
 var (
@@ -137,45 +138,35 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 			rhs = rhs_
 		}
 	case ConstValue:
-		value := rhs_.Convert(lhs.Type())
+		// Shift counts are not converted to the type of the left operand,
+		// as they may exceed its range.
+		var value Value
+		if op == token.SHL || op == token.SHR {
+			value = rhs_.Convert(types.Uint)
+		} else {
+			value = rhs_.Convert(lhs.Type())
+		}
 		rhs = c.NewLLVMValue(value.LLVMValue(), value.Type())
 	}
 
-	// Special case for structs.
-	// TODO handle strings as an even more special case.
-	if struct_type, ok := types.Underlying(lhs.typ).(*types.Struct); ok {
-		// TODO check types are the same.
-
-		element_types_count := lhs.LLVMValue().Type().StructElementTypesCount()
-		struct_fields := struct_type.Fields
-
-		if element_types_count > 0 {
-			t := c.ObjGetType(struct_fields[0])
-			first_lhs := c.NewLLVMValue(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), t)
-			first_rhs := c.NewLLVMValue(b.CreateExtractValue(rhs.LLVMValue(), 0, ""), t)
-			first := first_lhs.BinaryOp(op, first_rhs)
-
-			logical_op := token.LAND
-			if op == token.NEQ {
-				logical_op = token.LOR
-			}
-
-			result := first
-			for i := 1; i < element_types_count; i++ {
-				t := c.ObjGetType(struct_fields[i])
-				next_lhs := c.NewLLVMValue(b.CreateExtractValue(lhs.LLVMValue(), i, ""), t)
-				next_rhs := c.NewLLVMValue(b.CreateExtractValue(rhs.LLVMValue(), i, ""), t)
-				next := next_lhs.BinaryOp(op, next_rhs)
-				result = result.BinaryOp(logical_op, next)
-			}
-			return result
+	// Comparing an interface value with a non-interface value compares
+	// the interface value with the other value converted to the
+	// interface's type.
+	if _, ok := types.Underlying(rhs.typ).(*types.Interface); ok {
+		if _, ok := types.Underlying(lhs.typ).(*types.Interface); !ok {
+			return rhs.BinaryOp(op, lhs)
 		}
 	}
 
+	// Structs and arrays are compared using their type's equality
+	// algorithm.
+	switch types.Underlying(lhs.typ).(type) {
+	case *types.Struct, *types.Array:
+		return lhs.compareAlgorithm(rhs)
+	}
+
 	// Interfaces.
 	if _, ok := types.Underlying(lhs.typ).(*types.Interface); ok {
-		// TODO check for interface/interface comparison vs. interface/value comparison.
-
 		// nil comparison
 		if /*rhs.LLVMValue().IsConstant() &&*/ rhs.LLVMValue().IsNull() {
 			var result llvm.Value
@@ -190,27 +181,18 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 			}
 			return c.NewLLVMValue(result, types.Bool)
 		}
-
-		// First, check that the dynamic types are identical.
-		// FIXME provide runtime function for type identity comparison, and
-		// value comparisons.
-		lhsType := b.CreateExtractValue(lhs.LLVMValue(), 1, "")
-		rhsType := b.CreateExtractValue(rhs.LLVMValue(), 1, "")
-		diff := b.CreatePtrDiff(lhsType, rhsType, "")
-		zero := llvm.ConstNull(diff.Type())
-
-		var result llvm.Value
-		if op == token.EQL {
-			typesIdentical := b.CreateICmp(llvm.IntEQ, diff, zero, "")
-			//valuesEqual := ...
-			//result = b.CreateAnd(typesIdentical, valuesEqual, "")
-			result = typesIdentical
-		} else {
-			typesDifferent := b.CreateICmp(llvm.IntNE, diff, zero, "")
-			//valuesUnequal := ...
-			//result = b.CreateOr(typesDifferent, valuesUnequal, "")
-			result = typesDifferent
+		if _, ok := types.Underlying(rhs.typ).(*types.Interface); !ok {
+			rhs = rhs.Convert(lhs.typ).(*LLVMValue)
 		}
+		return lhs.compareInterfaces(rhs)
+	}
+
+	// Func values may only be compared with nil, so we need only compare
+	// the function pointers.
+	if _, ok := types.Underlying(lhs.typ).(*types.Func); ok {
+		lhsptr := b.CreateExtractValue(lhs.LLVMValue(), 0, "")
+		rhsptr := b.CreateExtractValue(rhs.LLVMValue(), 0, "")
+		result = b.CreateICmp(llvm.IntEQ, lhsptr, rhsptr, "")
 		return c.NewLLVMValue(result, types.Bool)
 	}
 
@@ -228,45 +210,50 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		panic("unimplemented")
 	}
 
-	// Determine whether to use integer or floating point instructions.
-	// TODO determine the NaN rules.
-	isfp := types.Identical(types.Underlying(lhs.typ), types.Float32) ||
-		types.Identical(types.Underlying(lhs.typ), types.Float64)
+	if isFloatType(lhs.typ) {
+		return lhs.floatBinaryOp(op, rhs)
+	} else if isComplexType(lhs.typ) {
+		return lhs.complexBinaryOp(op, rhs)
+	}
 
 	switch op {
 	case token.MUL:
 		result = b.CreateMul(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
-	case token.QUO:
-		result = b.CreateUDiv(lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.QUO, token.REM:
+		return lhs.divide(op, rhs)
 	case token.ADD:
 		result = b.CreateAdd(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
 	case token.SUB:
 		result = b.CreateSub(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.AND:
+		result = b.CreateAnd(lhs.LLVMValue(), rhs.LLVMValue(), "")
+		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.OR:
+		result = b.CreateOr(lhs.LLVMValue(), rhs.LLVMValue(), "")
+		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.XOR:
+		result = b.CreateXor(lhs.LLVMValue(), rhs.LLVMValue(), "")
+		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.AND_NOT:
+		result = b.CreateAnd(lhs.LLVMValue(), b.CreateNot(rhs.LLVMValue(), ""), "")
+		return lhs.compiler.NewLLVMValue(result, lhs.typ)
+	case token.SHL, token.SHR:
+		return lhs.shift(op, rhs)
 	case token.NEQ:
-		if isfp {
-			result = b.CreateFCmp(llvm.FloatONE, lhs.LLVMValue(), rhs.LLVMValue(), "")
-		} else {
-			result = b.CreateICmp(llvm.IntNE, lhs.LLVMValue(), rhs.LLVMValue(), "")
-		}
+		result = b.CreateICmp(llvm.IntNE, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
 	case token.EQL:
 		result = b.CreateICmp(llvm.IntEQ, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
-	case token.LSS:
-		result = b.CreateICmp(llvm.IntULT, lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, types.Bool)
-	case token.LEQ: // TODO signed/unsigned
-		result = b.CreateICmp(llvm.IntULE, lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, types.Bool)
-	case token.GTR:
-		result = b.CreateICmp(llvm.IntUGT, lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, types.Bool)
-	case token.GEQ:
-		result = b.CreateICmp(llvm.IntUGE, lhs.LLVMValue(), rhs.LLVMValue(), "")
+	case token.LSS, token.LEQ, token.GTR, token.GEQ:
+		pred := signedPredicates[op]
+		if isUnsignedType(lhs.typ) {
+			pred = unsignedPredicates[op]
+		}
+		result = b.CreateICmp(pred, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
 	case token.LAND:
 		// FIXME change this to branch
@@ -282,16 +269,200 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 	panic("unreachable")
 }
 
+// compareAlgorithm compares two values of the same type using the equality
+// algorithm of the type. The operands and result are passed through stack
+// slots in the entry block, so that comparisons in a loop reuse them.
+func (lhs *LLVMValue) compareAlgorithm(rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	equal := c.types.algorithms(lhs.typ).equal
+	if equal.IsNull() {
+		panic(fmt.Sprint("Uncomparable type: ", lhs.typ))
+	}
+	ptrType := c.target.IntPtrType()
+	llvmtype := c.types.ToLLVM(lhs.typ)
+	lhsptr := c.entryAlloca(llvmtype, "")
+	rhsptr := c.entryAlloca(llvmtype, "")
+	b.CreateStore(lhs.LLVMValue(), lhsptr)
+	b.CreateStore(rhs.Convert(lhs.typ).LLVMValue(), rhsptr)
+	result := c.entryAlloca(llvm.Int1Type(), "")
+	size := c.target.TypeAllocSize(llvmtype)
+	args := []llvm.Value{
+		result,
+		llvm.ConstInt(ptrType, size, false),
+		b.CreatePtrToInt(lhsptr, ptrType, ""),
+		b.CreatePtrToInt(rhsptr, ptrType, "")}
+	c.createCall(equal, args)
+	return c.NewLLVMValue(b.CreateLoad(result, ""), types.Bool)
+}
+
+// compareInterfaces compares two interface values, by calling
+// runtime.ifaceeq with their dynamic types and value pointers.
+func (lhs *LLVMValue) compareInterfaces(rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	ptrType := c.target.IntPtrType()
+	paramTypes := []llvm.Type{ptrType, ptrType, ptrType, ptrType}
+	funcType := llvm.FunctionType(llvm.Int1Type(), paramTypes, false)
+	ifaceeq := c.namedFunction("runtime.ifaceeq", funcType)
+	args := []llvm.Value{
+		b.CreatePtrToInt(lhs.interfaceType(), ptrType, ""),
+		b.CreatePtrToInt(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), ptrType, ""),
+		b.CreatePtrToInt(rhs.interfaceType(), ptrType, ""),
+		b.CreatePtrToInt(b.CreateExtractValue(rhs.LLVMValue(), 0, ""), ptrType, "")}
+	result := c.createCall(ifaceeq, args)
+	return c.NewLLVMValue(result, types.Bool)
+}
+
+// floatBinaryOp compiles an arithmetic or comparison operation on floating
+// point operands. Comparisons are ordered, and so false if either operand
+// is NaN, except for "!=", which is unordered and so true.
+func (lhs *LLVMValue) floatBinaryOp(op token.Token, rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	x, y := lhs.LLVMValue(), rhs.LLVMValue()
+	var result llvm.Value
+	switch op {
+	case token.MUL:
+		result = b.CreateFMul(x, y, "")
+	case token.QUO:
+		result = b.CreateFDiv(x, y, "")
+	case token.ADD:
+		result = b.CreateFAdd(x, y, "")
+	case token.SUB:
+		result = b.CreateFSub(x, y, "")
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		result = b.CreateFCmp(floatPredicates[op], x, y, "")
+		return c.NewLLVMValue(result, types.Bool)
+	default:
+		panic(fmt.Sprint("Unimplemented operator: ", op))
+	}
+	return c.NewLLVMValue(result, lhs.typ)
+}
+
+var floatPredicates = map[token.Token]llvm.FloatPredicate{
+	token.EQL: llvm.FloatOEQ,
+	token.NEQ: llvm.FloatUNE,
+	token.LSS: llvm.FloatOLT,
+	token.LEQ: llvm.FloatOLE,
+	token.GTR: llvm.FloatOGT,
+	token.GEQ: llvm.FloatOGE,
+}
+
+var signedPredicates = map[token.Token]llvm.IntPredicate{
+	token.LSS: llvm.IntSLT,
+	token.LEQ: llvm.IntSLE,
+	token.GTR: llvm.IntSGT,
+	token.GEQ: llvm.IntSGE,
+}
+
+var unsignedPredicates = map[token.Token]llvm.IntPredicate{
+	token.LSS: llvm.IntULT,
+	token.LEQ: llvm.IntULE,
+	token.GTR: llvm.IntUGT,
+	token.GEQ: llvm.IntUGE,
+}
+
+// divide compiles integer division or remainder. Division by zero panics,
+// and dividing the most negative value of a signed type by -1 yields the
+// same value (with a remainder of zero), rather than overflowing.
+func (lhs *LLVMValue) divide(op token.Token, rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	x, y := lhs.LLVMValue(), rhs.LLVMValue()
+	zero := llvm.ConstNull(y.Type())
+
+	currBlock := b.GetInsertBlock()
+	panicBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	contBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	panicBlock.MoveAfter(currBlock)
+	contBlock.MoveAfter(panicBlock)
+	isZero := b.CreateICmp(llvm.IntEQ, y, zero, "")
+	b.CreateCondBr(isZero, panicBlock, contBlock)
+
+	b.SetInsertPointAtEnd(panicBlock)
+	panicdivide := c.namedFunction("runtime.panicdivide",
+		llvm.FunctionType(llvm.VoidType(), nil, false))
+	c.createCall(panicdivide, nil)
+	b.CreateUnreachable()
+	b.SetInsertPointAtEnd(contBlock)
+
+	var result llvm.Value
+	if isUnsignedType(lhs.typ) {
+		if op == token.QUO {
+			result = b.CreateUDiv(x, y, "")
+		} else {
+			result = b.CreateURem(x, y, "")
+		}
+	} else {
+		// Divide by 1 in place of -1, so that x / -1 cannot overflow;
+		// x / -1 is then -x, and x % -1 is 0 either way.
+		minusOne := llvm.ConstAllOnes(y.Type())
+		isMinusOne := b.CreateICmp(llvm.IntEQ, y, minusOne, "")
+		one := llvm.ConstInt(y.Type(), 1, false)
+		y = b.CreateSelect(isMinusOne, one, y, "")
+		if op == token.QUO {
+			result = b.CreateSDiv(x, y, "")
+			result = b.CreateSelect(isMinusOne, b.CreateNeg(x, ""), result, "")
+		} else {
+			result = b.CreateSRem(x, y, "")
+		}
+	}
+	return c.NewLLVMValue(result, lhs.typ)
+}
+
+// shift compiles a shift by a count of any unsigned integer type. Go
+// defines shifts by counts at least as large as the width of the operand:
+// left shifts and unsigned right shifts yield zero, and signed right shifts
+// yield 0 or -1 according to the sign of the operand.
+func (lhs *LLVMValue) shift(op token.Token, rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	x, count := lhs.LLVMValue(), rhs.LLVMValue()
+	bits := x.Type().IntTypeWidth()
+	width := llvm.ConstInt(count.Type(), uint64(bits), false)
+	oversized := b.CreateICmp(llvm.IntUGE, count, width, "")
+	switch countBits := count.Type().IntTypeWidth(); {
+	case countBits < bits:
+		count = b.CreateZExt(count, x.Type(), "")
+	case countBits > bits:
+		count = b.CreateTrunc(count, x.Type(), "")
+	}
+
+	var result llvm.Value
+	zero := llvm.ConstNull(x.Type())
+	switch {
+	case op == token.SHL:
+		result = b.CreateShl(x, count, "")
+		result = b.CreateSelect(oversized, zero, result, "")
+	case isUnsignedType(lhs.typ):
+		result = b.CreateLShr(x, count, "")
+		result = b.CreateSelect(oversized, zero, result, "")
+	default:
+		max := llvm.ConstInt(x.Type(), uint64(bits-1), false)
+		count = b.CreateSelect(oversized, max, count, "")
+		result = b.CreateAShr(x, count, "")
+	}
+	return c.NewLLVMValue(result, lhs.typ)
+}
+
 func (v *LLVMValue) UnaryOp(op token.Token) Value {
 	b := v.compiler.builder
 	switch op {
 	case token.SUB:
+		if isFloatType(v.typ) {
+			return v.compiler.NewLLVMValue(b.CreateFNeg(v.LLVMValue(), ""), v.typ)
+		} else if isComplexType(v.typ) {
+			re, im := v.compiler.complexParts(v)
+			re, im = b.CreateFNeg(re, ""), b.CreateFNeg(im, "")
+			return v.compiler.makeComplex(re, im, v.typ)
+		}
 		return v.compiler.NewLLVMValue(b.CreateNeg(v.LLVMValue(), ""), v.typ)
 	case token.ADD:
 		return v // No-op
 	case token.AND:
 		return v.pointer
-	case token.NOT:
+	case token.NOT, token.XOR:
 		value := b.CreateNot(v.LLVMValue(), "")
 		return v.compiler.NewLLVMValue(value, v.typ)
 	default:
@@ -328,7 +499,7 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 		if interface_, isinterface := dst_typ.(*types.Interface); isinterface {
 			return v.convertI2I(interface_)
 		} else {
-			return v.convertI2V(dst_typ)
+			return v.convertI2V(orig_dst_typ)
 		}
 	}
 
@@ -337,7 +508,23 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 		return v.convertV2I(interface_)
 	}
 
-	// TODO other special conversions, e.g. int->string.
+	// Channel conversions, which may only change direction.
+	if src, ischan := src_typ.(*types.Chan); ischan {
+		if dst, ischan := dst_typ.(*types.Chan); ischan {
+			if types.Identical(src.Elt, dst.Elt) {
+				return v.compiler.NewLLVMValue(v.LLVMValue(), orig_dst_typ)
+			}
+		}
+	}
+
+	// String conversions.
+	if isStringType(dst_typ) {
+		return v.compiler.convertToString(v, orig_dst_typ)
+	} else if _, isslice := dst_typ.(*types.Slice); isslice && isStringType(src_typ) {
+		return v.compiler.convertFromString(v, orig_dst_typ)
+	}
+
+	// TODO other special conversions.
 	llvm_type := v.compiler.types.ToLLVM(dst_typ)
 
 	// Unsafe pointer conversions.
@@ -357,8 +544,14 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 		}
 	}
 
-	// FIXME select the appropriate cast here, depending on size, type (int/float)
-	// and sign.
+	// Complex conversions, which may only change the size of the parts.
+	if isComplexType(src_typ) && isComplexType(dst_typ) {
+		return v.compiler.convertComplex(v, orig_dst_typ)
+	}
+
+	// Numeric conversions, selecting the cast according to the size,
+	// kind (int/float) and signedness of the types.
+	b := v.compiler.builder
 	lv := v.LLVMValue()
 	srcType := lv.Type()
 	switch srcType.TypeKind() { // source type
@@ -370,12 +563,40 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 			delta := srcBits - dstBits
 			switch {
 			case delta < 0:
-				// TODO check if (un)signed, use S/ZExt accordingly.
-				lv = v.compiler.builder.CreateZExt(lv, llvm_type, "")
+				if isUnsignedType(src_typ) {
+					lv = b.CreateZExt(lv, llvm_type, "")
+				} else {
+					lv = b.CreateSExt(lv, llvm_type, "")
+				}
 			case delta > 0:
-				lv = v.compiler.builder.CreateTrunc(lv, llvm_type, "")
+				lv = b.CreateTrunc(lv, llvm_type, "")
+			}
+			return v.compiler.NewLLVMValue(lv, orig_dst_typ)
+		case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+			if isUnsignedType(src_typ) {
+				lv = b.CreateUIToFP(lv, llvm_type, "")
+			} else {
+				lv = b.CreateSIToFP(lv, llvm_type, "")
+			}
+			return v.compiler.NewLLVMValue(lv, orig_dst_typ)
+		}
+	case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+		switch llvm_type.TypeKind() {
+		case llvm.IntegerTypeKind:
+			if isUnsignedType(dst_typ) {
+				lv = b.CreateFPToUI(lv, llvm_type, "")
+			} else {
+				lv = b.CreateFPToSI(lv, llvm_type, "")
+			}
+			return v.compiler.NewLLVMValue(lv, orig_dst_typ)
+		case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+			switch {
+			case srcType.TypeKind() == llvm.FloatTypeKind && llvm_type.TypeKind() == llvm.DoubleTypeKind:
+				lv = b.CreateFPExt(lv, llvm_type, "")
+			case srcType.TypeKind() == llvm.DoubleTypeKind && llvm_type.TypeKind() == llvm.FloatTypeKind:
+				lv = b.CreateFPTrunc(lv, llvm_type, "")
 			}
-			return v.compiler.NewLLVMValue(lv, dst_typ)
+			return v.compiler.NewLLVMValue(lv, orig_dst_typ)
 		}
 	}
 	//bitcast_value := v.compiler.builder.CreateBitCast(lv, llvm_type, "")
@@ -424,9 +645,15 @@ func (v *LLVMValue) makePointee() *LLVMValue {
 func (lhs ConstValue) BinaryOp(op token.Token, rhs_ Value) Value {
 	switch rhs := rhs_.(type) {
 	case *LLVMValue:
-		// Cast untyped lhs to rhs type.
+		// Cast untyped lhs to rhs type. The untyped left operand of a
+		// shift takes the type it would have alone, which is int if it
+		// cannot be determined from the context.
 		if _, ok := lhs.typ.(*types.Basic); ok {
-			lhs = lhs.Convert(rhs.Type()).(ConstValue)
+			if op == token.SHL || op == token.SHR {
+				lhs = lhs.Convert(types.Int).(ConstValue)
+			} else {
+				lhs = lhs.Convert(rhs.Type()).(ConstValue)
+			}
 		}
 		lhs_ := rhs.compiler.NewLLVMValue(lhs.LLVMValue(), lhs.Type())
 		return lhs_.BinaryOp(op, rhs)
@@ -469,6 +696,20 @@ func (v ConstValue) Convert(dst_typ types.Type) Value {
 
 		compiler := v.compiler
 		if isBasic {
+			if x, isint := v.Val.(*big.Int); isint && isStringType(dst_typ) {
+				// Integer constants convert to the UTF-8 encoding of
+				// the rune, or "\uFFFD" if it is not a valid rune.
+				r := rune(unicode.ReplacementChar)
+				if x.Sign() >= 0 && x.Cmp(big.NewInt(unicode.MaxRune)) <= 0 {
+					r = rune(x.Int64())
+				}
+				return ConstValue{types.Const{Val: string(r)}, compiler, dst_typ}
+			}
+			if x, israt := v.Val.(*big.Rat); israt && isIntType(dst_typ) && x.IsInt() {
+				// Floating point constants representable as integers
+				// may be converted to integer types.
+				return ConstValue{types.Const{Val: new(big.Int).Set(x.Num())}, compiler, dst_typ}
+			}
 			return ConstValue{*v.Const.Convert(&dst_typ), compiler, dst_typ}
 		} else {
 			return compiler.NewLLVMValue(v.LLVMValue(), v.Type()).Convert(dst_typ)
@@ -483,6 +724,19 @@ func (v ConstValue) Convert(dst_typ types.Type) Value {
 
 func (v ConstValue) LLVMValue() llvm.Value {
 	typ := v.Type()
+
+	// Constants of named basic types are represented as constants of the
+	// predeclared type with the same kind.
+	for name, isname := typ.(*types.Name); isname; name, isname = typ.(*types.Name) {
+		if basic, isbasic := name.Underlying.(*types.Basic); isbasic {
+			if obj := types.Universe.Lookup(basic.Kind.String()); obj != nil {
+				typ = obj.Type.(types.Type)
+			}
+			break
+		}
+		typ = name.Underlying
+	}
+
 	switch typ {
 	case types.Int:
 		// TODO 32/64bit
@@ -510,12 +764,38 @@ func (v ConstValue) LLVMValue() llvm.Value {
 	case types.Int64:
 		return llvm.ConstInt(llvm.Int64Type(), uint64(v.Int64()), true)
 	case types.Uint64:
-		return llvm.ConstInt(llvm.Int64Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(llvm.Int64Type(), v.Val.(*big.Int).Uint64(), false)
+
+	case types.Uint:
+		inttype := v.compiler.target.IntPtrType()
+		return llvm.ConstInt(inttype, v.Val.(*big.Int).Uint64(), false)
 
 	case types.UnsafePointer, types.Uintptr:
 		inttype := v.compiler.target.IntPtrType()
 		return llvm.ConstInt(inttype, uint64(v.Int64()), false)
 
+	case types.Float32:
+		f, _ := v.rat().Float32()
+		return llvm.ConstFloat(llvm.FloatType(), float64(f))
+	case types.Float64:
+		f, _ := v.rat().Float64()
+		return llvm.ConstFloat(llvm.DoubleType(), f)
+
+	case types.Complex64:
+		re, im := v.Const.Complex()
+		ref, _ := re.Float32()
+		imf, _ := im.Float32()
+		return llvm.ConstStruct([]llvm.Value{
+			llvm.ConstFloat(llvm.FloatType(), float64(ref)),
+			llvm.ConstFloat(llvm.FloatType(), float64(imf))}, false)
+	case types.Complex128:
+		re, im := v.Const.Complex()
+		ref, _ := re.Float64()
+		imf, _ := im.Float64()
+		return llvm.ConstStruct([]llvm.Value{
+			llvm.ConstFloat(llvm.DoubleType(), ref),
+			llvm.ConstFloat(llvm.DoubleType(), imf)}, false)
+
 	case types.String:
 		strval := (v.Val).(string)
 		ptr := v.compiler.builder.CreateGlobalStringPtr(strval, "")
@@ -561,6 +841,18 @@ func (v ConstValue) Int64() int64 {
 	return int_val.Int64()
 }
 
+// rat returns the value of an integer or floating point constant as a
+// big.Rat, from which floating point constants are rounded exactly once.
+func (v ConstValue) rat() *big.Rat {
+	switch x := v.Val.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(x)
+	case *big.Rat:
+		return x
+	}
+	panic(fmt.Sprintf("unexpected constant value: %v", v.Val))
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 // TypeValue
 